@@ -0,0 +1,25 @@
+package httputils // import "github.com/docker/docker/api/server/httputils"
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// APIFunc is an adapter to allow the use of ordinary functions as Docker API
+// endpoints. vars holds the path parameters the router matched (e.g. "id",
+// "name"), the same way every other route in this package receives them.
+type APIFunc func(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error
+
+// ParseBool parses raw as a query-parameter boolean, falling back to def
+// when raw is empty or not a recognized boolean form.
+func ParseBool(raw string, def bool) bool {
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return def
+	}
+	return v
+}
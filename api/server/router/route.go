@@ -0,0 +1,28 @@
+package router // import "github.com/docker/docker/api/server/router"
+
+import "github.com/docker/docker/api/server/httputils"
+
+// Route defines an individual API route in the docker server.
+type Route interface {
+	// Handler returns the raw function to create the http handler.
+	Handler() httputils.APIFunc
+	// Method returns the http method that the route responds to.
+	Method() string
+	// Path returns the subpath where the route responds to.
+	Path() string
+}
+
+type localRoute struct {
+	method  string
+	path    string
+	handler httputils.APIFunc
+}
+
+func (r localRoute) Handler() httputils.APIFunc { return r.handler }
+func (r localRoute) Method() string             { return r.method }
+func (r localRoute) Path() string               { return r.path }
+
+// NewGetRoute initializes a new route with the http method GET.
+func NewGetRoute(path string, handler httputils.APIFunc) Route {
+	return localRoute{method: "GET", path: path, handler: handler}
+}
@@ -0,0 +1,8 @@
+package router // import "github.com/docker/docker/api/server/router"
+
+// Router defines an interface to specify a group of routes to add to the
+// docker server.
+type Router interface {
+	// Routes returns the list of routes to add to the docker server.
+	Routes() []Route
+}
@@ -0,0 +1,20 @@
+package stats // import "github.com/docker/docker/api/server/router/stats"
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types/backend"
+	daemonstats "github.com/docker/docker/daemon/stats"
+)
+
+// Backend is the methods required by the stats router, matching what
+// daemon/stats.go already implements.
+type Backend interface {
+	ContainerMetrics(ctx context.Context, prefixOrName string, out io.Writer) error
+	AllContainersMetrics(ctx context.Context, out io.Writer) error
+	ServiceMetrics(ctx context.Context, serviceName string, out io.Writer) error
+	MultiContainerStats(ctx context.Context, filter daemonstats.MultiStatsFilter, config *backend.ContainerStatsConfig) error
+	ContainerStatsHistory(ctx context.Context, prefixOrName string, since, until time.Time, step time.Duration) ([]daemonstats.HistoryBucket, error)
+}
@@ -0,0 +1,31 @@
+package stats // import "github.com/docker/docker/api/server/router/stats"
+
+import "github.com/docker/docker/api/server/router"
+
+// statsRouter is a router to talk with the stats controller.
+type statsRouter struct {
+	backend Backend
+	routes  []router.Route
+}
+
+// NewRouter initializes a new stats router.
+func NewRouter(b Backend) router.Router {
+	r := &statsRouter{backend: b}
+	r.initRoutes()
+	return r
+}
+
+// Routes returns the available routers to the stats controller.
+func (r *statsRouter) Routes() []router.Route {
+	return r.routes
+}
+
+func (r *statsRouter) initRoutes() {
+	r.routes = []router.Route{
+		router.NewGetRoute("/metrics", r.getAllContainersMetrics),
+		router.NewGetRoute("/containers/{id:.*}/metrics", r.getMetrics),
+		router.NewGetRoute("/services/{name:.*}/metrics", r.getServiceMetrics),
+		router.NewGetRoute("/containers/stats/multi", r.getMultiContainerStats),
+		router.NewGetRoute("/containers/{id:.*}/stats/history", r.getContainerStatsHistory),
+	}
+}
@@ -0,0 +1,106 @@
+package stats // import "github.com/docker/docker/api/server/router/stats"
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/server/httputils"
+	"github.com/docker/docker/api/types/backend"
+	daemonstats "github.com/docker/docker/daemon/stats"
+)
+
+func (s *statsRouter) getMetrics(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	return s.backend.ContainerMetrics(ctx, vars["id"], w)
+}
+
+// getAllContainersMetrics serves GET /metrics, covering every running
+// container on the daemon in a single scrape target.
+func (s *statsRouter) getAllContainersMetrics(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	return s.backend.AllContainersMetrics(ctx, w)
+}
+
+func (s *statsRouter) getServiceMetrics(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	return s.backend.ServiceMetrics(ctx, vars["name"], w)
+}
+
+// getMultiContainerStats wires MultiContainerStats to a single endpoint
+// covering many containers at once, selected by the same
+// containers/label/service/all query parameters, which map onto the fields of
+// daemonstats.MultiStatsFilter.
+func (s *statsRouter) getMultiContainerStats(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	query := r.URL.Query()
+
+	filter := daemonstats.MultiStatsFilter{
+		Service: query.Get("service"),
+		All:     httputils.ParseBool(query.Get("all"), false),
+	}
+	if containers := query.Get("containers"); containers != "" {
+		filter.Containers = strings.Split(containers, ",")
+	}
+	if labels := query["label"]; len(labels) > 0 {
+		filter.Labels = make(map[string]string, len(labels))
+		for _, kv := range labels {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				filter.Labels[k] = v
+			}
+		}
+	}
+
+	config := &backend.ContainerStatsConfig{
+		Stream:    httputils.ParseBool(query.Get("stream"), true),
+		OneShot:   httputils.ParseBool(query.Get("one-shot"), false),
+		OutStream: w,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return s.backend.MultiContainerStats(ctx, filter, config)
+}
+
+// getContainerStatsHistory serves GET .../stats/history, returning the
+// downsampled buckets ContainerStatsHistory computes from the daemon's
+// --stats-retention store. since/until are RFC3339 timestamps; until
+// defaults to now and since to until minus an hour. step defaults to
+// whatever ContainerStatsHistory's own History.Query falls back to when
+// given zero.
+func (s *statsRouter) getContainerStatsHistory(ctx context.Context, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	query := r.URL.Query()
+
+	until := time.Now()
+	if raw := query.Get("until"); raw != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return err
+		}
+		until = t
+	}
+	since := until.Add(-time.Hour)
+	if raw := query.Get("since"); raw != "" {
+		t, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return err
+		}
+		since = t
+	}
+	var step time.Duration
+	if raw := query.Get("step"); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		step = d
+	}
+
+	buckets, err := s.backend.ContainerStatsHistory(ctx, vars["id"], since, until, step)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(buckets)
+}
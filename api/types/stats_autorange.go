@@ -0,0 +1,13 @@
+package types // import "github.com/docker/docker/api/types"
+
+// AutoRangeSummary is the final resource-usage report produced by the
+// AutoRange collector when the container it is watching stops, or once its
+// prediction window finishes. It lets operators see the peaks and averages
+// that drove the applied limits without having to replay the whole series.
+type AutoRangeSummary struct {
+	MaxMemory, MinMemory, MeanMemory uint64
+	MaxCPUPercent, MeanCPUPercent    float64
+	SamplesTaken                     int
+	AmplitudePeak                    uint64
+	HitConfiguredMax                 bool
+}
@@ -0,0 +1,25 @@
+package swarm // import "github.com/docker/docker/api/types/swarm"
+
+import "time"
+
+// AutoScale describes a service's replica-scaling policy, parallel to
+// AutoRange's per-container resource-tuning policy. Where AutoRange re-tunes
+// the limits of a single container, AutoScale re-tunes a service's replica
+// count based on the same kind of aggregated utilization signal.
+type AutoScale struct {
+	// MinReplicas and MaxReplicas bound the replica count AutoScale will
+	// ever set, regardless of what the utilization ratio suggests.
+	MinReplicas, MaxReplicas uint64
+
+	// TargetCPUPercent and TargetMemoryPercent are the per-task utilization
+	// AutoScale tries to hold the service at. A zero value disables scaling
+	// on that dimension.
+	TargetCPUPercent, TargetMemoryPercent float64
+
+	// Cooldown is the minimum time between two scaling actions, so a burst
+	// of samples past the threshold doesn't thrash the replica count.
+	Cooldown time.Duration
+
+	// Step is how many replicas are added or removed per scaling action.
+	Step uint64
+}
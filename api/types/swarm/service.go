@@ -0,0 +1,70 @@
+package swarm // import "github.com/docker/docker/api/types/swarm"
+
+import "time"
+
+// Version represents the internal object version.
+type Version struct {
+	Index uint64 `json:",omitempty"`
+}
+
+// Meta is a common object shared by most top-level swarm objects.
+type Meta struct {
+	Version Version
+}
+
+// Annotations represents how to describe an object.
+type Annotations struct {
+	Name   string            `json:",omitempty"`
+	Labels map[string]string `json:",omitempty"`
+}
+
+// ReplicatedService describes a service that runs a fixed number of replicas.
+type ReplicatedService struct {
+	Replicas *uint64 `json:",omitempty"`
+}
+
+// ServiceMode represents the scheduling mode of a service.
+type ServiceMode struct {
+	Replicated *ReplicatedService `json:",omitempty"`
+}
+
+// AutoRange is a per-resource map of tuning parameters (e.g. "memoryAR" ->
+// {"nmax": ..., "usage": ...}), the wire shape AutoRangeWatcher.Config and
+// stats.ConvertAutoRange already assume.
+type AutoRange map[string]map[string]string
+
+// ServiceSpec represents the spec of a service.
+type ServiceSpec struct {
+	Annotations
+
+	Mode ServiceMode `json:",omitempty"`
+
+	// AutoRange is this service's per-container resource auto-tuning
+	// policy (see daemon/stats.AutoRangeWatcher). Nil disables it.
+	AutoRange AutoRange `json:",omitempty"`
+
+	// AutoScale is this service's replica auto-scaling policy (see
+	// daemon/stats.AutoScaleWatcher). Nil disables it.
+	AutoScale *AutoScale `json:",omitempty"`
+}
+
+// ScaleDecision is the wire shape of daemon/stats.AutoScaleWatcher's current
+// scaling decision, surfaced on Service so operators can debug why (or
+// whether) AutoScale has scaled a service without reading daemon logs.
+type ScaleDecision struct {
+	DesiredReplicas, CurrentReplicas uint64
+	Reason                           string
+	LastScale                        time.Time
+}
+
+// Service represents a swarm service.
+type Service struct {
+	ID string
+	Meta
+	Spec ServiceSpec
+
+	// ScaleDecision is AutoScaleWatcher's last decision for this service, or
+	// nil if AutoScale isn't active for it. Populated by ServiceInspect, not
+	// stored as part of the spec.
+	ScaleDecision *ScaleDecision `json:",omitempty"`
+}
@@ -4,12 +4,20 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/sirupsen/logrus"
+
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/backend"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/api/types/versions"
 	"github.com/docker/docker/api/types/versions/v1p20"
@@ -20,15 +28,22 @@ import (
 	"github.com/docker/docker/pkg/ioutils"
 )
 
-func getAutoRange(ctx context.Context, containerID string) (swarm.AutoRange, string, bool) {
+// getAutoRange returns, on success, the service's AutoRange spec, its real
+// service ID (the identifier ServiceInspectWithRaw/ServiceUpdate actually
+// accept), its display name, and its annotation labels. Callers that talk to
+// the service API again later (e.g. persistSummary) must use the ID, not the
+// display name: docker stack deploy names services "<stack>_<service>", and
+// AutoRangeWatcher.ServiceName is truncated further still for display, so
+// neither round-trips through ServiceInspectWithRaw.
+func getAutoRange(ctx context.Context, containerID string) (swarm.AutoRange, string, string, map[string]string, bool) {
 	cli, err := client.NewEnvClient()
 	if err != nil {
-		return swarm.AutoRange{}, "", false
+		return swarm.AutoRange{}, "", "", nil, false
 	}
 	defer cli.Close()
 	container, err := cli.ContainerInspect(ctx, containerID)
 	if err != nil {
-		return swarm.AutoRange{}, "", false
+		return swarm.AutoRange{}, "", "", nil, false
 	}
 
 	// Swarm labels needed to get AutoRange configuration
@@ -36,10 +51,176 @@ func getAutoRange(ctx context.Context, containerID string) (swarm.AutoRange, str
 	if serviceID != "" && serviceName != "" {
 		resp, _, _ := cli.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
 		if resp.Spec.AutoRange != nil {
-			return resp.Spec.AutoRange, serviceName, true
+			return resp.Spec.AutoRange, serviceID, serviceName, resp.Spec.Annotations.Labels, true
+		}
+	}
+	return swarm.AutoRange{}, "", "", nil, false
+}
+
+// getAutoScale is getAutoRange's counterpart for the replica-scaling policy:
+// it resolves the swarm service behind containerID and returns its
+// AutoScale spec, labels, and service name, if any.
+func getAutoScale(ctx context.Context, containerID string) (swarm.AutoScale, string, map[string]string, bool) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return swarm.AutoScale{}, "", nil, false
+	}
+	defer cli.Close()
+	ctr, err := cli.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return swarm.AutoScale{}, "", nil, false
+	}
+
+	serviceID, serviceName := ctr.Config.Labels["com.docker.swarm.service.id"], ctr.Config.Labels["com.docker.swarm.service.name"]
+	if serviceID == "" || serviceName == "" {
+		return swarm.AutoScale{}, "", nil, false
+	}
+
+	resp, _, _ := cli.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
+	if resp.Spec.AutoScale == nil {
+		return swarm.AutoScale{}, "", nil, false
+	}
+	return *resp.Spec.AutoScale, serviceName, resp.Spec.Annotations.Labels, true
+}
+
+// ServiceInspect resolves serviceName through the cluster backend and
+// attaches this daemon's current AutoScaleWatcher decision for it, if any,
+// so operators can see desired vs. current replicas and the last scale
+// reason without reading daemon logs.
+func (daemon *Daemon) ServiceInspect(ctx context.Context, serviceName string) (swarm.Service, error) {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return swarm.Service{}, err
+	}
+	defer cli.Close()
+
+	service, _, err := cli.ServiceInspectWithRaw(ctx, serviceName, types.ServiceInspectOptions{})
+	if err != nil {
+		return swarm.Service{}, err
+	}
+
+	if watcher, exist := daemon.statsCollector.AutoScaleWatcher[service.Spec.Annotations.Name]; exist {
+		decision := watcher.Decision()
+		service.ScaleDecision = &swarm.ScaleDecision{
+			DesiredReplicas: decision.DesiredReplicas,
+			CurrentReplicas: decision.CurrentReplicas,
+			Reason:          decision.Reason,
+			LastScale:       decision.LastScale,
+		}
+	}
+	return service, nil
+}
+
+// plainAutoRangeLabelPrefix is the container label prefix a plain
+// `docker run --autorange memory.min=...` container carries its AutoRange
+// config under, mirroring the "autorange:" compose keys a swarm service
+// gets from its ServiceSpec instead.
+const plainAutoRangeLabelPrefix = "com.docker.autorange."
+
+// getPlainAutoRange builds an AutoRange config straight from a non-swarm
+// container's own labels, so AutoRange can drive a plain container with no
+// swarm mode at all, per com.docker.autorange.<category>.<key>=<value>
+// labels such as com.docker.autorange.memory.min.
+func getPlainAutoRange(ctr *container.Container) (swarm.AutoRange, bool) {
+	autoRange := swarm.AutoRange{}
+	for label, value := range ctr.Config.Labels {
+		key, ok := strings.CutPrefix(label, plainAutoRangeLabelPrefix)
+		if !ok {
+			continue
+		}
+		category, subKey, ok := strings.Cut(key, ".")
+		if !ok {
+			continue
+		}
+		if autoRange[category] == nil {
+			autoRange[category] = make(map[string]string)
+		}
+		autoRange[category][subKey] = value
+	}
+	return autoRange, len(autoRange) > 0
+}
+
+// isSwarmLeader reports whether this daemon is currently the swarm manager
+// in control, so AutoScaleWatcher.Watch only acts on one node at a time.
+func (daemon *Daemon) isSwarmLeader(ctx context.Context) bool {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return false
+	}
+	defer cli.Close()
+
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return false
+	}
+	return info.Swarm.ControlAvailable
+}
+
+// runAutoScaleFanIn feeds watcher.Input with one frame per tick from every
+// running container belonging to serviceName, joining newly-started task
+// containers and dropping stopped ones the same way MultiContainerStats
+// does for its own subscribers.
+func (daemon *Daemon) runAutoScaleFanIn(ctx context.Context, serviceName string, watcher *stats.AutoScaleWatcher) {
+	filter := stats.MultiStatsFilter{Service: serviceName}
+	joined := make(map[string]context.CancelFunc)
+
+	rescan := func() {
+		matches := daemon.matchMultiStatsFilter(ctx, filter)
+		for id, cancel := range joined {
+			if !matches[id] {
+				cancel()
+				delete(joined, id)
+			}
+		}
+		for id := range matches {
+			if _, exist := joined[id]; exist {
+				continue
+			}
+			ctrCtx, cancel := context.WithCancel(ctx)
+			joined[id] = cancel
+			go daemon.feedAutoScaleWatcher(ctrCtx, id, watcher)
+		}
+	}
+
+	rescan()
+	ticker := time.NewTicker(multiStatsRescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rescan()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// feedAutoScaleWatcher subscribes to a single container's stats and pushes
+// every frame onto watcher.Input until ctx is cancelled or the subscription
+// ends.
+func (daemon *Daemon) feedAutoScaleWatcher(ctx context.Context, idOrName string, watcher *stats.AutoScaleWatcher) {
+	ctr, err := daemon.GetContainer(idOrName)
+	if err != nil {
+		return
+	}
+
+	updates := daemon.subscribeToContainerStats(ctr)
+	defer daemon.unsubscribeToContainerStats(ctr, updates)
+
+	for {
+		select {
+		case v, ok := <-updates:
+			if !ok {
+				return
+			}
+			select {
+			case watcher.Input <- v.(types.StatsJSON):
+			default:
+			}
+		case <-ctx.Done():
+			return
 		}
 	}
-	return swarm.AutoRange{}, "", false
 }
 
 // ContainerStats writes information about the container to the stream
@@ -70,7 +251,7 @@ func (daemon *Daemon) ContainerStats(ctx context.Context, prefixOrName string, c
 	}
 
 	// AutoRange initialisation
-	if autoRange, serviceName, ok := getAutoRange(ctx, ctr.ID); ok {
+	if autoRange, serviceID, serviceName, serviceLabels, ok := getAutoRange(ctx, ctr.ID); ok {
 		if _, exist := daemon.statsCollector.AutoRangeWatcher[serviceName]; exist {
 			if daemon.statsCollector.AutoRangeWatcher[serviceName].Target != ctr {
 				daemon.statsCollector.AutoRangeWatcher[serviceName].Target = ctr
@@ -78,18 +259,28 @@ func (daemon *Daemon) ContainerStats(ctx context.Context, prefixOrName string, c
 			}
 		} else if _, exist := daemon.statsCollector.AutoRangeWatcher[ctr.ID]; !exist {
 			limit := 10 // Size limit of timeserie
+			seedMemMin, seedMemMax, seedCPUMin, seedCPUMax := stats.SeedFromLabels(serviceLabels)
+			input := make(chan types.StatsJSON, 1)
 			daemon.statsCollector.AutoRangeWatcher[ctr.ID] = &stats.AutoRangeWatcher{
-				Config:      autoRange,
-				TickRate:    time.Second,
-				Target:      ctr,
-				ServiceName: serviceName[:strings.LastIndex(serviceName, "_")],
-				Input:       make(chan types.StatsJSON, 1),
-				Output:      make(chan types.StatsJSON, 1),
-				WaitChan:    make(chan bool, 1),
-				Obs:         stats.NewObservor(limit),
-				Ctx:         ctx,
-				Limit:       limit,
-				Finished:    false,
+				Config:        autoRange,
+				TickRate:      time.Second,
+				Target:        ctr,
+				ServiceID:     serviceID,
+				ServiceName:   serviceName[:strings.LastIndex(serviceName, "_")],
+				Input:         input,
+				Output:        make(chan types.StatsJSON, 1),
+				WaitChan:      make(chan bool, 1),
+				Obs:           stats.NewObservor(limit),
+				Ctx:           ctx,
+				Limit:         limit,
+				Finished:      false,
+				SeedMemoryMin: seedMemMin,
+				SeedMemoryMax: seedMemMax,
+				SeedCPUMin:    seedCPUMin,
+				SeedCPUMax:    seedCPUMax,
+				Sampler:       stats.NewSwarmStatsSampler(input),
+				Applier:       stats.LocalDaemonApplier{},
+				Alert:         stats.LogrusAlertSink{},
 			}
 			go func() {
 				daemon.statsCollector.AutoRangeWatcher[ctr.ID].Watch()
@@ -99,6 +290,44 @@ func (daemon *Daemon) ContainerStats(ctx context.Context, prefixOrName string, c
 		} else if !daemon.statsCollector.AutoRangeWatcher[ctr.ID].Finished {
 			daemon.statsCollector.AutoRangeWatcher[ctr.ID].SetNewContext(ctx)
 		}
+	} else if autoRange, ok := getPlainAutoRange(ctr); ok {
+		// Plain, non-swarm container: drive AutoRange straight off the
+		// container's own cgroup instead of the swarm-facing collector, so
+		// `docker run --autorange ...` works with no swarm mode required.
+		// There's no swarm service to re-key onto once Watch returns, so this
+		// watcher stays keyed under ctr.ID for its whole lifetime.
+		if _, exist := daemon.statsCollector.AutoRangeWatcher[ctr.ID]; !exist {
+			limit := 10 // Size limit of timeserie
+			watcher := stats.NewAutoRangeWatcher(autoRange, ctr, ctr.Name, limit, stats.NewContainerSampler(ctr.ID), stats.LocalDaemonApplier{})
+			watcher.Ctx = ctx
+			daemon.statsCollector.AutoRangeWatcher[ctr.ID] = watcher
+			go watcher.Watch()
+		} else if !daemon.statsCollector.AutoRangeWatcher[ctr.ID].Finished {
+			daemon.statsCollector.AutoRangeWatcher[ctr.ID].SetNewContext(ctx)
+		}
+	}
+
+	// AutoScale initialisation: one watcher per service, fanning in every
+	// task container's stats, regardless of which container's ContainerStats
+	// call happened to trigger it.
+	if autoScale, serviceName, serviceLabels, ok := getAutoScale(ctx, ctr.ID); ok {
+		if _, exist := daemon.statsCollector.AutoScaleWatcher[serviceName]; !exist {
+			scaleCtx, cancel := context.WithCancel(ctx)
+			watcher := stats.NewAutoScaleWatcher(autoScale, serviceName, stats.LocalSwarmScaleApplier{}, func() bool {
+				return daemon.isSwarmLeader(scaleCtx)
+			})
+			watcher.Ctx = scaleCtx
+			if lastScale, replicas := stats.LastScaleFromLabels(serviceLabels); replicas > 0 || !lastScale.IsZero() {
+				watcher.Seed(lastScale, replicas)
+			}
+
+			daemon.statsCollector.AutoScaleWatcher[serviceName] = watcher
+			go daemon.runAutoScaleFanIn(scaleCtx, serviceName, watcher)
+			go func() {
+				watcher.Watch()
+				cancel()
+			}()
+		}
 	}
 
 	outStream := config.OutStream
@@ -167,6 +396,10 @@ func (daemon *Daemon) ContainerStats(ctx context.Context, prefixOrName string, c
 					statsJSONPost120.AutoRange = stats.ConvertAutoRange(daemon.statsCollector.AutoRangeWatcher[ctr.ID].Config)
 				}
 			}
+			if daemon.statsCollector.History != nil {
+				daemon.statsCollector.History.Record(ctr.ID, statsJSONPost120.Read,
+					statsJSONPost120.CPUStats.CPUUsage.TotalUsage, statsJSONPost120.MemoryStats.Usage, statsJSONPost120.MemoryStats.Limit)
+			}
 			if versions.LessThan(apiVersion, "1.21") {
 				var (
 					rxBytes   uint64
@@ -224,6 +457,175 @@ func (daemon *Daemon) ContainerStats(ctx context.Context, prefixOrName string, c
 	}
 }
 
+// MultiContainerStats fans in stats from every container matching filter
+// onto a single chunked response, instead of the caller opening one
+// ContainerStats connection per container. Each frame is tagged with its
+// container's id/name so the client can demultiplex them. It re-evaluates
+// filter every multiStatsRescanInterval so containers started or stopped
+// after the initial match are joined or dropped without restarting the
+// request.
+func (daemon *Daemon) MultiContainerStats(ctx context.Context, filter stats.MultiStatsFilter, config *backend.ContainerStatsConfig) error {
+	outStream := config.OutStream
+	if config.Stream {
+		wf := ioutils.NewWriteFlusher(outStream)
+		defer wf.Close()
+		wf.Flush()
+		outStream = wf
+	}
+
+	var encMu sync.Mutex
+	enc := json.NewEncoder(outStream)
+
+	joined := make(map[string]context.CancelFunc)
+	var joinedMu sync.Mutex
+
+	join := func(id string) {
+		joinedMu.Lock()
+		if _, exist := joined[id]; exist {
+			joinedMu.Unlock()
+			return
+		}
+		ctrCtx, cancel := context.WithCancel(ctx)
+		joined[id] = cancel
+		joinedMu.Unlock()
+
+		go func() {
+			daemon.streamOneOfMany(ctrCtx, id, config, &encMu, enc)
+			joinedMu.Lock()
+			delete(joined, id)
+			joinedMu.Unlock()
+		}()
+	}
+
+	rescan := func() {
+		matches := daemon.matchMultiStatsFilter(ctx, filter)
+		joinedMu.Lock()
+		dropped := make([]string, 0, len(joined))
+		for id := range joined {
+			if !matches[id] {
+				dropped = append(dropped, id)
+			}
+		}
+		joinedMu.Unlock()
+		for _, id := range dropped {
+			joinedMu.Lock()
+			if cancel, exist := joined[id]; exist {
+				cancel()
+			}
+			joinedMu.Unlock()
+		}
+		for id := range matches {
+			join(id)
+		}
+	}
+
+	if !config.Stream {
+		// Block until every matched container has written its one frame
+		// instead of fanning goroutines out and returning immediately: the
+		// caller's response is considered complete the moment this call
+		// returns, so any goroutine still writing into enc/outStream after
+		// that would race the connection teardown.
+		matches := daemon.matchMultiStatsFilter(ctx, filter)
+		var wg sync.WaitGroup
+		for id := range matches {
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				daemon.streamOneOfMany(ctx, id, config, &encMu, enc)
+			}(id)
+		}
+		wg.Wait()
+		return nil
+	}
+
+	rescan()
+	ticker := time.NewTicker(multiStatsRescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rescan()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// multiStatsRescanInterval is how often MultiContainerStats re-evaluates its
+// filter against the current set of containers.
+const multiStatsRescanInterval = 5 * time.Second
+
+// matchMultiStatsFilter resolves filter against the daemon's running
+// containers, returning the set of container IDs to stream.
+func (daemon *Daemon) matchMultiStatsFilter(ctx context.Context, filter stats.MultiStatsFilter) map[string]bool {
+	matches := make(map[string]bool)
+
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return matches
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(),
+	})
+	if err != nil {
+		return matches
+	}
+
+	for _, c := range containers {
+		if filter.Matches(c.ID, c.Labels) {
+			matches[c.ID] = true
+			continue
+		}
+		for _, name := range c.Names {
+			if filter.Matches(strings.TrimPrefix(name, "/"), c.Labels) {
+				matches[c.ID] = true
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// streamOneOfMany subscribes to a single container's stats and writes each
+// frame to the shared encoder, tagged with the container's id, until ctx is
+// cancelled or the container's stream ends. config.OneShot still applies
+// per-container; so does config.Stream == false, which — same as
+// ContainerStats's own single-frame reply — always terminates after one
+// frame regardless of OneShot, so a non-stream MultiContainerStats call has a
+// bounded number of frames to wait for instead of hanging until ctx is done.
+func (daemon *Daemon) streamOneOfMany(ctx context.Context, idOrName string, config *backend.ContainerStatsConfig, encMu *sync.Mutex, enc *json.Encoder) {
+	ctr, err := daemon.GetContainer(idOrName)
+	if err != nil {
+		return
+	}
+
+	updates := daemon.subscribeToContainerStats(ctr)
+	defer daemon.unsubscribeToContainerStats(ctr, updates)
+
+	for {
+		select {
+		case v, ok := <-updates:
+			if !ok {
+				return
+			}
+			ss := v.(types.StatsJSON)
+			ss.Name = ctr.Name
+			ss.ID = ctr.ID
+
+			encMu.Lock()
+			err := enc.Encode(&ss)
+			encMu.Unlock()
+			if err != nil || config.OneShot || !config.Stream {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (daemon *Daemon) subscribeToContainerStats(c *container.Container) chan interface{} {
 	return daemon.statsCollector.Collect(c)
 }
@@ -248,3 +650,211 @@ func (daemon *Daemon) GetContainerStats(container *container.Container) (*types.
 
 	return stats, nil
 }
+
+// defaultStatsRetention is a default retention window for the HistoryStore
+// backing ContainerStatsHistory. Nothing reads it: NewCollector takes its
+// retention as an argument, and no --stats-retention flag is defined here.
+const defaultStatsRetention = 15 * time.Minute
+
+// ContainerStatsHistory returns a downsampled view of a container's retained
+// stats, backing GET /containers/{id}/stats/history. It reads from the same
+// HistoryStore every ContainerStats call records into, independent of
+// whether AutoRange is enabled for the container.
+func (daemon *Daemon) ContainerStatsHistory(ctx context.Context, prefixOrName string, since, until time.Time, step time.Duration) ([]stats.HistoryBucket, error) {
+	ctr, err := daemon.GetContainer(prefixOrName)
+	if err != nil {
+		return nil, err
+	}
+
+	if daemon.statsCollector.History == nil {
+		return nil, nil
+	}
+	return daemon.statsCollector.History.Query(ctr.ID, since, until, step), nil
+}
+
+// collectContainerSample is the "stats -> structured samples" step of the
+// Prometheus metrics endpoints. It reads the frame through GetContainerStats,
+// the same call the Collector's poll loop makes for ContainerStats' stream;
+// ContainerStats itself does not call this function. It returns the stats
+// frame plus the container's swarm service name when that service carries an
+// AutoRange spec, and "" otherwise.
+func (daemon *Daemon) collectContainerSample(ctx context.Context, ctr *container.Container) (*types.StatsJSON, string, error) {
+	frame, err := daemon.GetContainerStats(ctr)
+	if err != nil {
+		return nil, "", err
+	}
+	frame.Name, frame.ID = ctr.Name, ctr.ID
+
+	_, _, serviceName, _, ok := getAutoRange(ctx, ctr.ID)
+	if !ok {
+		return frame, "", nil
+	}
+
+	if watcher, exist := daemon.statsCollector.AutoRangeWatcher[serviceName]; exist {
+		frame.AutoRange = stats.ConvertAutoRange(watcher.Config)
+	}
+
+	return frame, serviceName, nil
+}
+
+// ContainerMetrics renders a single container's stats as Prometheus text
+// exposition format, for scrapers polling GET /containers/{id}/metrics
+// instead of the streaming `docker stats` JSON.
+func (daemon *Daemon) ContainerMetrics(ctx context.Context, prefixOrName string, out io.Writer) error {
+	return daemon.writeContainerMetrics(ctx, prefixOrName, newMetricsWriter(out))
+}
+
+// AllContainersMetrics renders Prometheus metrics for every running
+// container on the daemon, so a single scrape target (GET /metrics) can
+// cover the whole host the same way ServiceMetrics covers one service.
+func (daemon *Daemon) AllContainersMetrics(ctx context.Context, out io.Writer) error {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{})
+	if err != nil {
+		return err
+	}
+
+	w := newMetricsWriter(out)
+	for _, c := range containers {
+		if err := daemon.writeContainerMetrics(ctx, c.ID, w); err != nil {
+			logrus.Errorf("metrics: failed to collect stats for container %s: %v", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// ServiceMetrics renders Prometheus metrics for every running container
+// backing a swarm service, so a single scrape target can cover a whole
+// service instead of one target per task.
+func (daemon *Daemon) ServiceMetrics(ctx context.Context, serviceName string, out io.Writer) error {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", "com.docker.swarm.service.name="+serviceName)),
+	})
+	if err != nil {
+		return err
+	}
+
+	// One metricsWriter shared across every container in the service, so
+	// HELP/TYPE lines appear exactly once in the response instead of once
+	// per container (which would be invalid exposition format for anything
+	// past the first task).
+	w := newMetricsWriter(out)
+	for _, c := range containers {
+		if err := daemon.writeContainerMetrics(ctx, c.ID, w); err != nil {
+			logrus.Errorf("metrics: failed to collect stats for container %s: %v", c.ID, err)
+		}
+	}
+	return nil
+}
+
+// writeContainerMetrics is the shared body of ContainerMetrics and
+// ServiceMetrics, taking a metricsWriter so a multi-container scrape can
+// render every container through the same HELP/TYPE bookkeeping.
+func (daemon *Daemon) writeContainerMetrics(ctx context.Context, prefixOrName string, w *metricsWriter) error {
+	ctr, err := daemon.GetContainer(prefixOrName)
+	if err != nil {
+		return err
+	}
+
+	frame, serviceName, err := daemon.collectContainerSample(ctx, ctr)
+	if err != nil {
+		return err
+	}
+
+	renderContainerMetrics(w, frame, serviceName, ctr.Config.Image, daemon.statsCollector.AutoRangeWatcher[serviceName])
+	return nil
+}
+
+var containerMetricHelp = map[string]string{
+	"container_cpu_usage_seconds_total":      "Total CPU time consumed, in seconds.",
+	"container_memory_usage_bytes":           "Current memory usage, in bytes.",
+	"container_memory_limit_bytes":           "Memory limit applied to the container, in bytes.",
+	"container_network_receive_bytes_total":  "Total bytes received per network interface.",
+	"container_network_transmit_bytes_total": "Total bytes transmitted per network interface.",
+	"container_autorange_memory_target":      "AutoRange predicted memory limit (nmax).",
+	"container_autorange_memory_current":     "AutoRange last observed memory usage.",
+	"container_autorange_cpu_target":         "AutoRange predicted CPU usage target.",
+	"container_autorange_cpu_current":        "AutoRange last observed CPU percent.",
+}
+
+// metricsWriter accumulates the Prometheus text-exposition state for a
+// single scrape response: which metric names have already had their HELP/TYPE
+// lines written. Scoping that state to one metricsWriter per call, instead of
+// a package-level map, is what makes it safe for concurrent scrapes (no
+// shared map to race on) and correct for sequential ones (every response gets
+// its own HELP/TYPE lines instead of only the first one ever rendered).
+type metricsWriter struct {
+	out         io.Writer
+	helpWritten map[string]bool
+}
+
+func newMetricsWriter(out io.Writer) *metricsWriter {
+	return &metricsWriter{out: out, helpWritten: make(map[string]bool)}
+}
+
+func (w *metricsWriter) writeMetric(name string, labels map[string]string, value float64) {
+	if !w.helpWritten[name] {
+		fmt.Fprintf(w.out, "# HELP %s %s\n# TYPE %s gauge\n", name, containerMetricHelp[name], name)
+		w.helpWritten[name] = true
+	}
+
+	labelPairs := make([]string, 0, len(labels))
+	for key, val := range labels {
+		labelPairs = append(labelPairs, fmt.Sprintf(`%s=%q`, key, val))
+	}
+	sort.Strings(labelPairs)
+
+	fmt.Fprintf(w.out, "%s{%s} %v\n", name, strings.Join(labelPairs, ","), value)
+}
+
+// renderContainerMetrics renders frame (and, if the container is under
+// AutoRange, watcher's latest observed values) as Prometheus samples. image
+// is omitted from the labels entirely when the caller couldn't resolve one,
+// rather than mislabeling it with another field.
+func renderContainerMetrics(w *metricsWriter, frame *types.StatsJSON, serviceName, image string, watcher *stats.AutoRangeWatcher) {
+	labels := map[string]string{
+		"id":   frame.ID,
+		"name": strings.TrimPrefix(frame.Name, "/"),
+	}
+	if image != "" {
+		labels["image"] = image
+	}
+	if serviceName != "" {
+		labels["service"] = serviceName
+	}
+
+	w.writeMetric("container_cpu_usage_seconds_total", labels, float64(frame.CPUStats.CPUUsage.TotalUsage)/1e9)
+	w.writeMetric("container_memory_usage_bytes", labels, float64(frame.MemoryStats.Usage))
+	w.writeMetric("container_memory_limit_bytes", labels, float64(frame.MemoryStats.Limit))
+
+	for iface, net := range frame.Networks {
+		ifaceLabels := map[string]string{"id": labels["id"], "name": labels["name"], "interface": iface}
+		w.writeMetric("container_network_receive_bytes_total", ifaceLabels, float64(net.RxBytes))
+		w.writeMetric("container_network_transmit_bytes_total", ifaceLabels, float64(net.TxBytes))
+	}
+
+	if watcher == nil || watcher.Obs == nil {
+		return
+	}
+
+	nmax, _ := strconv.ParseFloat(watcher.Config["memoryAR"]["nmax"], 64)
+	usage, _ := strconv.ParseFloat(watcher.Config["memoryAR"]["usage"], 64)
+	cpuTarget, _ := strconv.ParseFloat(watcher.Config["cpuAR"]["usageOpti"], 64)
+	cpuUsage, _ := strconv.ParseFloat(watcher.Config["cpuAR"]["usage"], 64)
+
+	w.writeMetric("container_autorange_memory_target", labels, nmax)
+	w.writeMetric("container_autorange_memory_current", labels, usage)
+	w.writeMetric("container_autorange_cpu_target", labels, cpuTarget)
+	w.writeMetric("container_autorange_cpu_current", labels, cpuUsage)
+}
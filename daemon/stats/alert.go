@@ -0,0 +1,117 @@
+package stats // import "github.com/docker/docker/daemon/stats"
+
+import (
+	"encoding/json"
+	"net"
+	"strconv"
+	"time"
+
+	eventtypes "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/daemon/events"
+	"github.com/sirupsen/logrus"
+)
+
+// AlertLevel is the severity of a threshold-crossing alert fired by an
+// AutoRangeWatcher. The empty value means no alert is currently active.
+type AlertLevel string
+
+// Alert levels, from least to most severe.
+const (
+	AlertNone AlertLevel = ""
+	AlertWarn AlertLevel = "warn"
+	AlertCrit AlertLevel = "crit"
+)
+
+// AlertSink receives threshold-crossing notifications from an
+// AutoRangeWatcher. OnThreshold fires once a resource stays past level for
+// alertConsecutiveSamples samples, OnRecovery once it drops back below
+// level minus the configured hysteresis for the same number of samples.
+type AlertSink interface {
+	OnThreshold(container, resource string, level AlertLevel, value, limit float64)
+	OnRecovery(container, resource string, level AlertLevel, value, limit float64)
+}
+
+// LogrusAlertSink reports alerts through the daemon's regular log output.
+type LogrusAlertSink struct{}
+
+// OnThreshold logs a warning when usage crosses into warn/crit territory.
+func (LogrusAlertSink) OnThreshold(container, resource string, level AlertLevel, value, limit float64) {
+	logrus.Warnf("autorange: container %s %s usage %.2f crossed %s threshold (limit %.2f)", container, resource, value, level, limit)
+}
+
+// OnRecovery logs that a previously alerted resource has recovered.
+func (LogrusAlertSink) OnRecovery(container, resource string, level AlertLevel, value, limit float64) {
+	logrus.Infof("autorange: container %s %s usage %.2f recovered from %s threshold (limit %.2f)", container, resource, value, level, limit)
+}
+
+type alertMessage struct {
+	Container string     `json:"container"`
+	Resource  string     `json:"resource"`
+	Level     AlertLevel `json:"level"`
+	Value     float64    `json:"value"`
+	Limit     float64    `json:"limit"`
+	Recovered bool       `json:"recovered"`
+	Time      time.Time  `json:"time"`
+}
+
+// SocketAlertSink streams alerts as newline-delimited JSON over a Unix
+// socket, so external supervisors can react without scraping daemon logs.
+type SocketAlertSink struct {
+	conn net.Conn
+}
+
+// NewSocketAlertSink dials addr (a Unix socket path) and returns a sink that
+// writes one JSON object per alert to the connection.
+func NewSocketAlertSink(addr string) (*SocketAlertSink, error) {
+	conn, err := net.Dial("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &SocketAlertSink{conn: conn}, nil
+}
+
+func (s *SocketAlertSink) send(msg alertMessage) {
+	if err := json.NewEncoder(s.conn).Encode(msg); err != nil {
+		logrus.Errorf("autorange: failed to write alert to socket: %v", err)
+	}
+}
+
+// OnThreshold writes the threshold crossing as a JSON message.
+func (s *SocketAlertSink) OnThreshold(container, resource string, level AlertLevel, value, limit float64) {
+	s.send(alertMessage{Container: container, Resource: resource, Level: level, Value: value, Limit: limit, Time: time.Now()})
+}
+
+// OnRecovery writes the recovery as a JSON message.
+func (s *SocketAlertSink) OnRecovery(container, resource string, level AlertLevel, value, limit float64) {
+	s.send(alertMessage{Container: container, Resource: resource, Level: level, Value: value, Limit: limit, Recovered: true, Time: time.Now()})
+}
+
+// EventAlertSink surfaces alerts on the regular Docker event stream, so
+// `docker events` and anything subscribed to it sees them like any other
+// container event.
+type EventAlertSink struct {
+	Events *events.Events
+}
+
+// OnThreshold logs a container event whose action is the resource name
+// (e.g. "memory"), with level/value/limit as attributes.
+func (e *EventAlertSink) OnThreshold(container, resource string, level AlertLevel, value, limit float64) {
+	e.log(container, resource, string(level), value, limit)
+}
+
+// OnRecovery logs a container event whose action is "recovery-<resource>"
+// (e.g. "recovery-memory").
+func (e *EventAlertSink) OnRecovery(container, resource string, level AlertLevel, value, limit float64) {
+	e.log(container, "recovery-"+resource, string(level), value, limit)
+}
+
+func (e *EventAlertSink) log(container, action, level string, value, limit float64) {
+	e.Events.Log(action, eventtypes.ContainerEventType, eventtypes.Actor{
+		ID: container,
+		Attributes: map[string]string{
+			"level": level,
+			"value": strconv.FormatFloat(value, 'f', 2, 64),
+			"limit": strconv.FormatFloat(limit, 'f', 2, 64),
+		},
+	})
+}
@@ -11,7 +11,10 @@ package stats // import "github.com/docker/docker/daemon/stats"
  It was written as a way to answer the question
 	 `How to optimise the number of services running on our infrastructure without losing quality of service?`
  How?
- It uses swarm labels and require swarm mode to be enabled (see #improvements).
+ By default it uses swarm labels and requires swarm mode to be enabled, but the
+ metrics source and the way limits get applied are both pluggable (see Sampler
+ and Applier in sampler.go), so a Cgroupv1Sampler/Cgroupv2Sampler can drive it
+ for a plain container with no swarm mode at all.
  The logic behind the feature can be described in 3 points:
  - First, we collect the metrics and apply transformations on it to generate two values.
  Those values represent a “box” around the actual consumption.
@@ -39,6 +42,33 @@ package stats // import "github.com/docker/docker/daemon/stats"
           The `docker container stats` command is mandatory to start and keep running the collector.
  You can always leave the docker container stats screen and
  come back later, the mechanism will be paused and the accumulated datas won’t be lost.
+ An optional warmup key can be set to avoid cold-start spikes polluting the first
+ predicted values, e.g.
+ autorange:
+ warmup:
+         duration: "30s"
+ While warming up, usage is still shown but not fed into the predictor, and the
+ AutoRange sub-status reported to docker container stats reads "warming-up".
+ Threshold alerts can be configured per resource with alert.warn%/alert.crit%
+ (alert.warn/alert.crit for cpu%) plus an optional alert.hysteresis%
+ (alert.hysteresis for cpu%, 5 when unset), e.g.
+ autorange:
+ memory:
+         alert.warn%: "80"
+         alert.crit%: "95"
+ Alerts fire after a few consecutive samples past a level and recover the
+ same way once usage drops level-hysteresis below it, see AlertSink.
+ Memory limits are no longer derived from flat averages of the retained
+ window: nmin tracks an EWMA of usage and nmax bounds the worst case to the
+ p95 tail plus a safety margin over the p95-p99 spread (see the predictor
+ sub-package), which keeps a single-window spike from dragging the whole
+ prediction up or down.
+ A service can also carry an autoscale key alongside autorange, describing
+ min/max replicas, target cpu%/memory%, a cooldown and a scale step. Unlike
+ AutoRangeWatcher, which tunes one container's limits, AutoScaleWatcher (see
+ autoscale.go) aggregates the stats of every task container of the service
+ and adjusts its replica count through the swarm cluster backend, and only
+ runs on the current swarm leader.
 */
 
 import (
@@ -54,6 +84,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/container"
+	"github.com/docker/docker/daemon/stats/predictor"
 	"github.com/sirupsen/logrus"
 )
 
@@ -104,12 +135,77 @@ type AutoRangeWatcher struct {
 	TickRate      time.Duration
 	Config        swarm.AutoRange
 	Target        *container.Container
-	ServiceName   string
-	Obs           *Observor
-	Ctx           context.Context
-	Limit         int
+	// ServiceID is the real swarm service identifier, suitable for
+	// ServiceInspectWithRaw/ServiceUpdate. ServiceName is display-only (and,
+	// for docker stack deploy services, truncated further still) and must
+	// never be used to address the service API.
+	ServiceID   string
+	ServiceName string
+	Obs         *Observor
+	Ctx         context.Context
+	Limit       int
+
+	// Warmup is the duration after the collector starts during which samples
+	// are displayed but not fed into the FIFO series or counted towards the
+	// prediction, so cold-start spikes don't skew the first window.
+	Warmup time.Duration
+
+	// Seed* are recovered from the previous run's Summary, persisted on the
+	// service annotations, so a redeploy doesn't start from the dummy floors.
+	SeedMemoryMin, SeedMemoryMax, SeedCPUMin, SeedCPUMax int
+
+	// Sampler and Applier decouple the prediction logic from where metrics
+	// come from and how limits get applied, so AutoRange isn't hard-wired to
+	// the swarm-facing collector and the local daemon API.
+	Sampler Sampler
+	Applier Applier
+
+	// Alert receives threshold-crossing notifications derived from the same
+	// series used for prediction. Nil disables alerting.
+	Alert AlertSink
+
+	alerts map[string]*alertTracker
+
+	// memory{EWMA,P50,P95,P99} bound nmin/nmax to a real tail quantile with
+	// O(1) memory per stream, instead of averaging the whole FIFO window.
+	memoryEWMA                      *predictor.EWMA
+	memoryP50, memoryP95, memoryP99 *predictor.P2Quantile
+
+	Finished, started, warmedUp bool
+}
+
+// NewAutoRangeWatcher builds a watcher driven by sampler and applier,
+// leaving the caller free to wire it to the swarm-facing collector and the
+// local daemon API (the original behavior) or to any other Sampler/Applier
+// pair, e.g. a Cgroupv1Sampler/Cgroupv2Sampler for non-swarm containers.
+func NewAutoRangeWatcher(config swarm.AutoRange, target *container.Container, serviceName string, limit int, sampler Sampler, applier Applier) *AutoRangeWatcher {
+	return &AutoRangeWatcher{
+		Config:      config,
+		TickRate:    time.Second,
+		Target:      target,
+		ServiceName: serviceName,
+		Obs:         NewObservor(limit),
+		Limit:       limit,
+		Output:      make(chan types.StatsJSON, 1),
+		WaitChan:    make(chan bool, 1),
+		Sampler:     sampler,
+		Applier:     applier,
+		Alert:       LogrusAlertSink{},
+	}
+}
+
+const summaryLabelPrefix = "com.docker.autorange.summary."
 
-	Finished, started bool
+// alertConsecutiveSamples is how many samples in a row must cross (or
+// recover from) a threshold before the corresponding alert fires, so a
+// single noisy tick doesn't flap the alert state.
+const alertConsecutiveSamples = 3
+
+// alertTracker keeps the per-(container,resource) state needed to apply
+// hysteresis and re-fire on a level change.
+type alertTracker struct {
+	firedLevel             AlertLevel
+	aboveCount, belowCount int
 }
 
 // NewObservor returns a newly initialized observor that will be used by the watcher
@@ -196,6 +292,20 @@ func highestOf(array []uint64) (highest int) {
 	return
 }
 
+func highestOfFloat(array []float64) (highest float64) {
+	if len(array) <= 0 {
+		return 0
+	}
+	highest = array[0]
+
+	for _, value := range array {
+		if value > highest {
+			highest = value
+		}
+	}
+	return
+}
+
 func percent(value int) (percent int) {
 	if value == 0 {
 		return value
@@ -281,19 +391,15 @@ func (ar *AutoRangeWatcher) UpdateResources() {
 
 	}
 
-	// Updating is done using the docker client API
-	cli, err := client.NewEnvClient()
-	if err != nil {
-		logrus.Errorf("%v\n", err)
-		return
-	}
-
+	// Applying is delegated to ar.Applier, so this isn't hard-wired to the
+	// local daemon API (see LocalDaemonApplier and the Sampler/Applier
+	// abstraction in sampler.go).
 	timer := time.Second * 30
 	ticker := time.NewTicker(timer)
 	count := 10
 	baseCount := count
 	for ; true; <-ticker.C {
-		_, err = cli.ContainerUpdate(ar.Ctx, ar.Target.ID, update)
+		err := ar.Applier.Apply(ar.Ctx, ar.Target.ID, update)
 		if err == nil {
 			logrus.Infof("container: %s (service: %s) now has limits applicated\n", ar.Target.Name, ar.ServiceName)
 			break
@@ -309,6 +415,115 @@ func (ar *AutoRangeWatcher) UpdateResources() {
 	return
 }
 
+// Summary walks the retained TimeSerieRAM/TimeSerieCPU series and produces a
+// final resource-usage report for the container. It is called once, on
+// container stop or when the prediction settles (Finished becomes true), so
+// operators get a human recap in the daemon logs and a last frame on the
+// Output channel, and so the next redeploy can be seeded from this run
+// instead of the dummy floors.
+func (ar *AutoRangeWatcher) Summary() types.AutoRangeSummary {
+	summary := types.AutoRangeSummary{
+		SamplesTaken: len(ar.Obs.TimeSerieRAM.usage),
+	}
+
+	if len(ar.Obs.TimeSerieRAM.usage) > 0 {
+		summary.MinMemory = lowestOf(ar.Obs.TimeSerieRAM.usage)
+		summary.MaxMemory = uint64(highestOf(ar.Obs.TimeSerieRAM.usage))
+		summary.MeanMemory = averrage(ar.Obs.TimeSerieRAM.usage)
+	}
+
+	if len(ar.Obs.TimeSerieRAM.amplitude) > 0 {
+		summary.AmplitudePeak = uint64(highestOf(ar.Obs.TimeSerieRAM.amplitude))
+	}
+
+	if appliedMax, err := strconv.ParseUint(ar.Config["memoryAR"]["sugmax"], 10, 64); err == nil {
+		summary.HitConfiguredMax = summary.MaxMemory >= appliedMax
+	}
+
+	if len(ar.Obs.TimeSerieCPU.percent) > 0 {
+		summary.MaxCPUPercent = highestOfFloat(ar.Obs.TimeSerieCPU.percent)
+		summary.MeanCPUPercent = averrageFloat(ar.Obs.TimeSerieCPU.percent)
+	}
+
+	logrus.Infof(
+		"container: %s (service: %s) autorange summary: memory min=%d max=%d mean=%d, cpu%% max=%.2f mean=%.2f, samples=%d, amplitudePeak=%d, hitConfiguredMax=%t",
+		ar.Target.Name, ar.ServiceName, summary.MinMemory, summary.MaxMemory, summary.MeanMemory,
+		summary.MaxCPUPercent, summary.MeanCPUPercent, summary.SamplesTaken, summary.AmplitudePeak, summary.HitConfiguredMax,
+	)
+
+	ar.Config["summaryAR"] = summaryToConfig(summary)
+	select {
+	case ar.Output <- types.StatsJSON{Name: ar.Target.Name, ID: ar.Target.ID, AutoRange: ConvertAutoRange(ar.Config)}:
+	default:
+	}
+
+	ar.persistSummary(summary)
+
+	return summary
+}
+
+func summaryToConfig(summary types.AutoRangeSummary) map[string]string {
+	return map[string]string{
+		"maxMemory":        strconv.FormatUint(summary.MaxMemory, 10),
+		"minMemory":        strconv.FormatUint(summary.MinMemory, 10),
+		"meanMemory":       strconv.FormatUint(summary.MeanMemory, 10),
+		"maxCPUPercent":    strconv.FormatFloat(summary.MaxCPUPercent, 'f', 3, 64),
+		"meanCPUPercent":   strconv.FormatFloat(summary.MeanCPUPercent, 'f', 3, 64),
+		"samplesTaken":     strconv.Itoa(summary.SamplesTaken),
+		"amplitudePeak":    strconv.FormatUint(summary.AmplitudePeak, 10),
+		"hitConfiguredMax": strconv.FormatBool(summary.HitConfiguredMax),
+	}
+}
+
+// persistSummary stores the summary on the service's own annotations, keyed
+// under summaryLabelPrefix, so a future redeploy can recover SeedMemoryMin /
+// SeedMemoryMax / SeedCPUMin / SeedCPUMax instead of starting from scratch.
+func (ar *AutoRangeWatcher) persistSummary(summary types.AutoRangeSummary) {
+	if ar.ServiceID == "" {
+		return
+	}
+
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		logrus.Errorf("autorange: %v", err)
+		return
+	}
+	defer cli.Close()
+
+	// ServiceID, not ServiceName: ServiceName is display-only (and, for
+	// docker stack deploy services, truncated further still), so it doesn't
+	// resolve through ServiceInspectWithRaw.
+	service, _, err := cli.ServiceInspectWithRaw(ar.Ctx, ar.ServiceID, types.ServiceInspectOptions{})
+	if err != nil {
+		logrus.Errorf("autorange: failed to persist summary for service %s: %v", ar.ServiceName, err)
+		return
+	}
+
+	if service.Spec.Annotations.Labels == nil {
+		service.Spec.Annotations.Labels = make(map[string]string)
+	}
+	for key, value := range summaryToConfig(summary) {
+		service.Spec.Annotations.Labels[summaryLabelPrefix+key] = value
+	}
+
+	if _, err := cli.ServiceUpdate(ar.Ctx, service.ID, service.Version, service.Spec, types.ServiceUpdateOptions{}); err != nil {
+		logrus.Errorf("autorange: failed to persist summary for service %s: %v", ar.ServiceName, err)
+	}
+}
+
+// SeedFromLabels recovers the AutoRangeWatcher Seed* fields from the service
+// labels a previous Summary persisted, so a redeploy starts from the last
+// observed usage instead of the dummy floors. Labels missing or unparsable
+// leave the corresponding seed at zero, which callers treat as "no seed".
+func SeedFromLabels(labels map[string]string) (memMin, memMax, cpuMin, cpuMax int) {
+	memMin, _ = strconv.Atoi(labels[summaryLabelPrefix+"minMemory"])
+	memMax, _ = strconv.Atoi(labels[summaryLabelPrefix+"maxMemory"])
+	meanCPU, _ := strconv.ParseFloat(labels[summaryLabelPrefix+"meanCPUPercent"], 64)
+	maxCPU, _ := strconv.ParseFloat(labels[summaryLabelPrefix+"maxCPUPercent"], 64)
+	cpuMin, cpuMax = int(meanCPU), int(maxCPU)
+	return
+}
+
 // IsActivated returns a true if category is found in config
 func (ar *AutoRangeWatcher) IsActivated(category string) bool {
 	_, exist := ar.Config[category]
@@ -343,14 +558,22 @@ func CPUUsageToConfig(usage string) (config, number string) {
 
 func (ar *AutoRangeWatcher) baseValueMemory() (min, max, threshold int) {
 	if ar.IsActivated("memory") {
+		memoryFloor, memoryCeiling := 10000, 20000
+		if ar.SeedMemoryMin > 0 {
+			memoryFloor = ar.SeedMemoryMin
+		}
+		if ar.SeedMemoryMax > 0 {
+			memoryCeiling = ar.SeedMemoryMax
+		}
+
 		min, _ = strconv.Atoi(ar.Config["memory"]["min"])
-		if min < 10000 {
-			min = 10000
+		if min < memoryFloor {
+			min = memoryFloor
 		}
 
 		max, _ = strconv.Atoi(ar.Config["memory"]["max"])
-		if max < 20000 {
-			max = 20000
+		if max < memoryCeiling {
+			max = memoryCeiling
 		}
 
 		threshold, _ = strconv.Atoi(ar.Config["memory"]["threshold"])
@@ -367,7 +590,13 @@ func (ar *AutoRangeWatcher) baseValueMemory() (min, max, threshold int) {
 func (ar *AutoRangeWatcher) baseValueCPU() (cpuMin, cpuMax int) {
 	if ar.IsActivated("cpu%") {
 		cpuMin, _ = strconv.Atoi(ar.Config["cpu%"]["min"])
+		if cpuMin == 0 {
+			cpuMin = ar.SeedCPUMin
+		}
 		cpuMax, _ = strconv.Atoi(ar.Config["cpu%"]["max"])
+		if cpuMax == 0 {
+			cpuMax = ar.SeedCPUMax
+		}
 		ar.Config["cpuAR"] = make(map[string]string)
 	} else {
 		cpuMin, cpuMax = 0, 0
@@ -375,10 +604,160 @@ func (ar *AutoRangeWatcher) baseValueCPU() (cpuMin, cpuMax int) {
 	return
 }
 
+func (ar *AutoRangeWatcher) baseValueWarmup() time.Duration {
+	raw, ok := ar.Config["warmup"]["duration"]
+	if !ok {
+		return 0
+	}
+	warmup, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0
+	}
+	return warmup
+}
+
+func parsePercent(raw string) float64 {
+	if raw == "" {
+		return 0
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0
+	}
+	return value / 100
+}
+
+// baseAlertMemory reads the memory.alert.warn%/crit%/hysteresis% compose
+// keys into fractions of the currently applied limit (e.g. 80 -> 0.8).
+// Missing or unparsable warn/crit keys return 0, which disables that level;
+// a missing or unparsable hysteresis defaults to 0.05.
+func (ar *AutoRangeWatcher) baseAlertMemory() (warnFrac, critFrac, hysteresisFrac float64) {
+	warnFrac = parsePercent(ar.Config["memory"]["alert.warn%"])
+	critFrac = parsePercent(ar.Config["memory"]["alert.crit%"])
+	hysteresisFrac = parsePercent(ar.Config["memory"]["alert.hysteresis%"])
+	if hysteresisFrac == 0 {
+		hysteresisFrac = 0.05
+	}
+	return
+}
+
+// baseAlertCPU is the cpu% equivalent of baseAlertMemory.
+func (ar *AutoRangeWatcher) baseAlertCPU() (warnFrac, critFrac, hysteresisFrac float64) {
+	warnFrac = parsePercent(ar.Config["cpu%"]["alert.warn"])
+	critFrac = parsePercent(ar.Config["cpu%"]["alert.crit"])
+	hysteresisFrac = parsePercent(ar.Config["cpu%"]["alert.hysteresis"])
+	if hysteresisFrac == 0 {
+		hysteresisFrac = 0.05
+	}
+	return
+}
+
+// memorySafety reads the memory.safety compose key, the multiplier applied
+// to the p95-p99 spread when deriving nmax. Defaults to 1.5 when unset.
+func (ar *AutoRangeWatcher) memorySafety() float64 {
+	raw, ok := ar.Config["memory"]["safety"]
+	if !ok {
+		return 1.5
+	}
+	safety, err := strconv.ParseFloat(raw, 64)
+	if err != nil || safety <= 0 {
+		return 1.5
+	}
+	return safety
+}
+
+// currentFloat reads key out of config as a float, falling back when the
+// key is absent, unparsable, or non-positive (not yet applied).
+func currentFloat(config map[string]string, key string, fallback float64) float64 {
+	if raw, ok := config[key]; ok {
+		if value, err := strconv.ParseFloat(raw, 64); err == nil && value > 0 {
+			return value
+		}
+	}
+	return fallback
+}
+
+func (ar *AutoRangeWatcher) alertTrackerFor(resource string) *alertTracker {
+	if ar.alerts == nil {
+		ar.alerts = make(map[string]*alertTracker)
+	}
+	tracker, ok := ar.alerts[resource]
+	if !ok {
+		tracker = &alertTracker{}
+		ar.alerts[resource] = tracker
+	}
+	return tracker
+}
+
+// evaluateAlert compares usage against limit*warnFrac/critFrac. It requires
+// alertConsecutiveSamples in a row past (or recovered from) a threshold
+// before firing, and re-fires on a level change (warn<->crit).
+func (ar *AutoRangeWatcher) evaluateAlert(resource string, usage, limit, warnFrac, critFrac, hysteresisFrac float64) {
+	if ar.Alert == nil || limit <= 0 || (warnFrac <= 0 && critFrac <= 0) {
+		return
+	}
+
+	tracker := ar.alertTrackerFor(resource)
+	ratio := usage / limit
+
+	level := AlertNone
+	switch {
+	case critFrac > 0 && ratio >= critFrac:
+		level = AlertCrit
+	case warnFrac > 0 && ratio >= warnFrac:
+		level = AlertWarn
+	}
+
+	if level != AlertNone {
+		tracker.belowCount = 0
+		if level == tracker.firedLevel {
+			tracker.aboveCount = 0
+		} else {
+			tracker.aboveCount++
+		}
+		if tracker.aboveCount >= alertConsecutiveSamples {
+			ar.Alert.OnThreshold(ar.Target.Name, resource, level, usage, limit)
+			tracker.firedLevel = level
+			tracker.aboveCount = 0
+		}
+		return
+	}
+
+	tracker.aboveCount = 0
+	if tracker.firedLevel == AlertNone {
+		return
+	}
+
+	recoveryFrac := warnFrac
+	if tracker.firedLevel == AlertCrit && critFrac > 0 {
+		recoveryFrac = critFrac
+	}
+	if ratio >= recoveryFrac-hysteresisFrac {
+		tracker.belowCount = 0
+		return
+	}
+
+	tracker.belowCount++
+	if tracker.belowCount >= alertConsecutiveSamples {
+		ar.Alert.OnRecovery(ar.Target.Name, resource, tracker.firedLevel, usage, limit)
+		tracker.firedLevel = AlertNone
+		tracker.belowCount = 0
+	}
+}
+
 func (ar *AutoRangeWatcher) isInBadState() bool {
 	return (ar.Target.State.Dead || !ar.Target.State.Running)
 }
 
+// isWarmingUp returns true while the collector is still within its Warmup
+// window, during which samples are shown but not used for prediction.
+func (ar *AutoRangeWatcher) isWarmingUp() bool {
+	if ar.Warmup <= 0 {
+		return false
+	}
+	return time.Since(ar.Obs.TimeSerieRAM.started) < ar.Warmup
+}
+
 func (ar *AutoRangeWatcher) isStarted() bool {
 	return ar.started
 }
@@ -415,6 +794,11 @@ func (ar *AutoRangeWatcher) startRoutine(ncpus uint32, cpuMin, cpuMax int) {
 	if cpuMin != 0 && cpuMax != 0 {
 		fifoFloat(ar.Obs.TimeSerieCPU.percent, float64(((cpuMin+cpuMax)/2)/int(ncpus)), ar.Limit)
 	}
+
+	now := time.Now()
+	ar.Obs.TimeSerieRAM.started = now
+	ar.Obs.TimeSerieCPU.started = now
+	ar.warmedUp = ar.Warmup <= 0
 	ar.started = true
 }
 
@@ -432,6 +816,16 @@ func (ar *AutoRangeWatcher) Watch() {
 
 	cpuMin, cpuMax := ar.baseValueCPU()
 
+	ar.Warmup = ar.baseValueWarmup()
+
+	memWarnFrac, memCritFrac, memHysteresisFrac := ar.baseAlertMemory()
+	cpuWarnFrac, cpuCritFrac, cpuHysteresisFrac := ar.baseAlertCPU()
+
+	ar.memoryEWMA = predictor.NewEWMA(0.1)
+	ar.memoryP50 = predictor.NewP2Quantile(0.5)
+	ar.memoryP95 = predictor.NewP2Quantile(0.95)
+	ar.memoryP99 = predictor.NewP2Quantile(0.99)
+
 	// Initialisation time
 	ticker := time.NewTicker(ar.TickRate)
 	time.Sleep(ar.TickRate)
@@ -439,18 +833,22 @@ func (ar *AutoRangeWatcher) Watch() {
 
 	logrus.Infof("container: %s (service: %s) started with activated autorange", ar.Target.Name, ar.ServiceName)
 	for range ticker.C {
-		select {
-		case in := <-ar.Input:
-			input = in
-		case <-ar.Ctx.Done(): // Handler for signal interrupt
-			<-ar.WaitChan
+		sample, err := ar.Sampler.Sample(ar.Ctx)
+		if err != nil {
+			if ar.Ctx.Err() != nil { // Handler for signal interrupt
+				<-ar.WaitChan
+				continue
+			}
+			logrus.Errorf("container: %s (service: %s) sampler error: %v", ar.Target.Name, ar.ServiceName, err)
 			continue
 		}
+		input = sample
 
 		// Healthchecking is required before every loops to ensure data integrity
 		// We don't want false prediction because the container was offline
 		if ar.isInBadState() {
 			logrus.Infof("container: %s (service: %s) exited, removing autorange", ar.Target.Name, ar.ServiceName)
+			ar.Summary()
 			return
 		}
 
@@ -462,15 +860,44 @@ func (ar *AutoRangeWatcher) Watch() {
 		} else if ar.isFinished() {
 			ar.UpdateResources()
 			ar.Finished = true
+			ar.Summary()
 			return
 		}
 
+		// The moment warmup ends, the extreme trackers carried over from the
+		// cold-start window are discarded so the first real window isn't skewed.
+		// oldUsage/oldSystem are deliberately left alone: they anchor the next
+		// CPU-percent delta, and zeroing them would make that first post-warmup
+		// sample compute usage since container start instead of since the last
+		// tick.
+		if !ar.warmedUp && !ar.isWarmingUp() {
+			lowest, highest = input.Stats.MemoryStats.Usage, 0
+			ar.warmedUp = true
+		}
+
 		for category := range ar.Config {
 			if continueIteration(category, "memory", ar.Obs.TimeSerieRAM.MemoryPrediction) {
 
-				// Follow memory usage and change min and max accordingly.
+				// Follow memory usage and change min and max accordingly, but only
+				// once warmed up: before that, min/max must stay pinned at the base
+				// values baseValueMemory returned, or cold-start spikes would warp the bearings
+				// the first real window inherits.
 				// These values represent the "bearings" around the usage value
-				min, max = processMemoryStats(input.Stats.MemoryStats.Usage, min, max, threshold)
+				if !ar.isWarmingUp() {
+					min, max = processMemoryStats(input.Stats.MemoryStats.Usage, min, max, threshold)
+				}
+
+				// The usage is always reported for display, even during warmup
+				ar.Config["memoryAR"]["usage"] = strconv.Itoa(int(input.Stats.MemoryStats.Usage))
+
+				if ar.isWarmingUp() {
+					ar.Config["memoryAR"]["status"] = "warming-up"
+					continue
+				}
+				ar.Config["memoryAR"]["status"] = "collecting"
+
+				appliedMemLimit := currentFloat(ar.Config["memoryAR"], "sugmax", float64(max))
+				ar.evaluateAlert("memory", float64(input.Stats.MemoryStats.Usage), appliedMemLimit, memWarnFrac, memCritFrac, memHysteresisFrac)
 
 				// Always get the lowest and highest point in the serie,
 				// as we'll use them for weighting purposes
@@ -480,6 +907,11 @@ func (ar *AutoRangeWatcher) Watch() {
 				ar.Obs.TimeSerieRAM.max = fifoUint(ar.Obs.TimeSerieRAM.max, uint64(max), ar.Limit)
 				ar.Obs.TimeSerieRAM.usage = fifoUint(ar.Obs.TimeSerieRAM.usage, input.Stats.MemoryStats.Usage, ar.Limit)
 
+				ar.memoryEWMA.Observe(float64(input.Stats.MemoryStats.Usage))
+				ar.memoryP50.Observe(float64(input.Stats.MemoryStats.Usage))
+				ar.memoryP95.Observe(float64(input.Stats.MemoryStats.Usage))
+				ar.memoryP99.Observe(float64(input.Stats.MemoryStats.Usage))
+
 				// Timeserie arrays are ready to be processed
 				if memoryTurn >= ar.Limit {
 					memoryTurn = 0
@@ -509,13 +941,16 @@ func (ar *AutoRangeWatcher) Watch() {
 
 					ar.Obs.TimeSerieRAM.MemoryPrediction = checkMemoryEndCondition(lenSerie, ar.Limit, medAmplitude)
 
-					// Display result
-					avMin := weightedAverrage(ar.Obs.TimeSerieRAM.PredictedValues.min, generateMemoryWeight(ar.Obs.TimeSerieRAM.PredictedValues.min, ar.Obs.TimeSerieRAM.lowest))
-					avMax := weightedAverrage(ar.Obs.TimeSerieRAM.PredictedValues.max, generateMemoryWeight(ar.Obs.TimeSerieRAM.PredictedValues.max, ar.Obs.TimeSerieRAM.highest))
-					ar.Config["memoryAR"]["nmin"] = strconv.Itoa(avMin + percent(avMin)*threshold)
-					ar.Config["memoryAR"]["nmax"] = strconv.Itoa(avMax + percent(avMax)*threshold)
+					// Display result: nmin tracks the EWMA of usage and nmax bounds the
+					// worst case to the p95 tail plus a safety margin over the p95-p99
+					// spread, instead of averaging the whole retained window.
+					p95, p99 := ar.memoryP95.Value(), ar.memoryP99.Value()
+					nmin := ar.memoryEWMA.Value()
+					nmax := p95 + ar.memorySafety()*(p99-p95)
+					ar.Config["memoryAR"]["nmin"] = strconv.FormatUint(uint64(nmin), 10)
+					ar.Config["memoryAR"]["nmax"] = strconv.FormatUint(uint64(nmax), 10)
+					ar.Config["memoryAR"]["median"] = strconv.FormatUint(uint64(ar.memoryP50.Value()), 10)
 					ar.Config["memoryAR"]["opti"] = strconv.Itoa(threshold)
-					ar.Config["memoryAR"]["usage"] = strconv.Itoa(int(input.Stats.MemoryStats.Usage))
 					continue
 				}
 				memoryTurn++
@@ -531,6 +966,19 @@ func (ar *AutoRangeWatcher) Watch() {
 				numCPUs := float64(input.Stats.CPUStats.OnlineCPUs)
 				CPUPercent := (deltaUsage / deltaSystem) * numCPUs * 100.0
 
+				// The percent is always reported for display, even during warmup
+				ar.Config["cpuAR"]["usage"] = strconv.FormatFloat(CPUPercent, 'f', 3, 64)
+				oldSystem, oldUsage = input.Stats.CPUStats.SystemUsage, input.Stats.CPUStats.CPUUsage.TotalUsage
+
+				if ar.isWarmingUp() {
+					ar.Config["cpuAR"]["status"] = "warming-up"
+					continue
+				}
+				ar.Config["cpuAR"]["status"] = "collecting"
+
+				appliedCPULimit := currentFloat(ar.Config["cpuAR"], "percentOpti", float64(cpuMax))
+				ar.evaluateAlert("cpu", CPUPercent, appliedCPULimit, cpuWarnFrac, cpuCritFrac, cpuHysteresisFrac)
+
 				ar.Obs.TimeSerieCPU.percent = fifoFloat(ar.Obs.TimeSerieCPU.percent, CPUPercent, ar.Limit)
 				ar.Obs.TimeSerieCPU.usage = fifoFloat(ar.Obs.TimeSerieCPU.usage, deltaUsage, ar.Limit)
 
@@ -555,7 +1003,6 @@ func (ar *AutoRangeWatcher) Watch() {
 					continue
 				}
 				cpuTurn++
-				oldSystem, oldUsage = input.Stats.CPUStats.SystemUsage, input.Stats.CPUStats.CPUUsage.TotalUsage
 			}
 		}
 
@@ -0,0 +1,299 @@
+package stats // import "github.com/docker/docker/daemon/stats"
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/daemon/stats/predictor"
+	"github.com/sirupsen/logrus"
+)
+
+// autoScaleLabelPrefix namespaces the annotations AutoScaleWatcher persists
+// on the service, mirroring summaryLabelPrefix for AutoRange.
+const autoScaleLabelPrefix = "com.docker.autoscale."
+
+// autoScaleConsecutiveSamples is how many windows in a row the utilization
+// ratio must stay past a threshold before AutoScale acts on it, the same
+// hysteresis AlertSink applies to threshold alerts.
+const autoScaleConsecutiveSamples = 3
+
+// ScaleApplier applies a replica count change to a swarm service, decoupling
+// AutoScaleWatcher from the cluster backend the same way Applier decouples
+// AutoRangeWatcher from the local daemon API.
+type ScaleApplier interface {
+	Scale(ctx context.Context, serviceName string, replicas uint64) error
+}
+
+// LocalSwarmScaleApplier updates a service's replica count through the local
+// Docker API.
+type LocalSwarmScaleApplier struct{}
+
+// Scale sets serviceName's replicated mode replica count to replicas.
+func (LocalSwarmScaleApplier) Scale(ctx context.Context, serviceName string, replicas uint64) error {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	service, _, err := cli.ServiceInspectWithRaw(ctx, serviceName, types.ServiceInspectOptions{})
+	if err != nil {
+		return err
+	}
+
+	if service.Spec.Mode.Replicated == nil {
+		return nil
+	}
+	service.Spec.Mode.Replicated.Replicas = &replicas
+
+	_, err = cli.ServiceUpdate(ctx, service.ID, service.Version, service.Spec, types.ServiceUpdateOptions{})
+	return err
+}
+
+// Decision is AutoScaleWatcher's current scaling decision, exposed so
+// ServiceInspect can surface it to operators debugging why (or whether) a
+// service scaled.
+type Decision struct {
+	DesiredReplicas, CurrentReplicas uint64
+	Reason                           string
+	LastScale                        time.Time
+}
+
+// AutoScaleWatcher aggregates the types.StatsJSON of every task container of
+// a service into a single utilization ratio and, when that ratio stays past
+// TargetCPUPercent/TargetMemoryPercent for long enough, scales the service's
+// replica count through Applier. Only the swarm leader should run it; IsLeader
+// is checked before every scaling action so a failover doesn't require
+// restarting the watcher.
+type AutoScaleWatcher struct {
+	Config      swarm.AutoScale
+	ServiceName string
+	// Input receives one frame per task container per tick, fed by whatever
+	// fans the service's containers in (see daemon/stats.go).
+	Input chan types.StatsJSON
+	Ctx   context.Context
+
+	Applier  ScaleApplier
+	IsLeader func() bool
+
+	utilizationEWMA *predictor.EWMA
+
+	// mu guards the fields below: they're mutated from Watch()'s goroutine
+	// on every tick, while Decision() is called from whatever goroutine
+	// handles a ServiceInspect request, concurrently with Watch().
+	mu                     sync.Mutex
+	aboveCount, belowCount int
+	currentReplicas        uint64
+	cooldownUntil          time.Time
+	lastDecision           Decision
+}
+
+// NewAutoScaleWatcher builds a watcher for serviceName driven by config,
+// scaling through applier only while isLeader() returns true.
+func NewAutoScaleWatcher(config swarm.AutoScale, serviceName string, applier ScaleApplier, isLeader func() bool) *AutoScaleWatcher {
+	return &AutoScaleWatcher{
+		Config:          config,
+		ServiceName:     serviceName,
+		Input:           make(chan types.StatsJSON, 16),
+		Applier:         applier,
+		IsLeader:        isLeader,
+		utilizationEWMA: predictor.NewEWMA(0.3),
+		currentReplicas: config.MinReplicas,
+	}
+}
+
+// utilizationRatio is the worse of the CPU and memory utilization ratios for
+// one frame, versus their configured targets. A zero target disables that
+// dimension.
+func (w *AutoScaleWatcher) utilizationRatio(frame types.StatsJSON) float64 {
+	ratio := 0.0
+
+	if w.Config.TargetCPUPercent > 0 {
+		cpuPercent := cpuPercentOf(frame)
+		if r := cpuPercent / w.Config.TargetCPUPercent; r > ratio {
+			ratio = r
+		}
+	}
+	if w.Config.TargetMemoryPercent > 0 && frame.MemoryStats.Limit > 0 {
+		memPercent := float64(frame.MemoryStats.Usage) / float64(frame.MemoryStats.Limit) * 100
+		if r := memPercent / w.Config.TargetMemoryPercent; r > ratio {
+			ratio = r
+		}
+	}
+	return ratio
+}
+
+func cpuPercentOf(frame types.StatsJSON) float64 {
+	cpuDelta := float64(frame.CPUStats.CPUUsage.TotalUsage) - float64(frame.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(frame.CPUStats.SystemUsage) - float64(frame.PreCPUStats.SystemUsage)
+	if systemDelta <= 0 || cpuDelta <= 0 {
+		return 0
+	}
+	onlineCPUs := float64(frame.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = 1
+	}
+	return (cpuDelta / systemDelta) * onlineCPUs * 100
+}
+
+// Watch folds every frame on Input into the utilization EWMA and, once the
+// ratio has stayed past 1.0 (over target) or well under it for
+// autoScaleConsecutiveSamples windows, scales the service through Applier,
+// subject to Cooldown and only while IsLeader returns true.
+func (w *AutoScaleWatcher) Watch() {
+	for {
+		select {
+		case frame, ok := <-w.Input:
+			if !ok {
+				return
+			}
+			w.utilizationEWMA.Observe(w.utilizationRatio(frame))
+			w.evaluate()
+		case <-w.Ctx.Done():
+			return
+		}
+	}
+}
+
+// evaluate applies the hysteresis/cooldown/leader checks and, if a scaling
+// action is due, calls Applier.Scale and persists the new last-scale
+// timestamp.
+func (w *AutoScaleWatcher) evaluate() {
+	ratio := w.utilizationEWMA.Value()
+
+	w.mu.Lock()
+	switch {
+	case ratio > 1.0:
+		w.aboveCount++
+		w.belowCount = 0
+	case ratio < 0.5:
+		w.belowCount++
+		w.aboveCount = 0
+	default:
+		w.aboveCount, w.belowCount = 0, 0
+	}
+
+	var desired uint64
+	var reason string
+	switch {
+	case w.aboveCount >= autoScaleConsecutiveSamples:
+		desired = w.currentReplicas + w.Config.Step
+		reason = "utilization above target for " + strconv.Itoa(w.aboveCount) + " consecutive windows"
+	case w.belowCount >= autoScaleConsecutiveSamples:
+		if w.currentReplicas > w.Config.Step {
+			desired = w.currentReplicas - w.Config.Step
+		}
+		reason = "utilization below target for " + strconv.Itoa(w.belowCount) + " consecutive windows"
+	default:
+		w.mu.Unlock()
+		return
+	}
+
+	if desired < w.Config.MinReplicas {
+		desired = w.Config.MinReplicas
+	}
+	if w.Config.MaxReplicas > 0 && desired > w.Config.MaxReplicas {
+		desired = w.Config.MaxReplicas
+	}
+	if desired == w.currentReplicas {
+		w.mu.Unlock()
+		return
+	}
+
+	currentReplicas, cooldownUntil := w.currentReplicas, w.cooldownUntil
+	w.lastDecision = Decision{DesiredReplicas: desired, CurrentReplicas: currentReplicas, Reason: reason, LastScale: w.lastDecision.LastScale}
+	w.mu.Unlock()
+
+	// IsLeader and Applier.Scale can both make network calls; never make
+	// those while holding mu, or a slow one would stall every concurrent
+	// Decision() call for its duration.
+	if !w.IsLeader() || time.Now().Before(cooldownUntil) {
+		return
+	}
+
+	if err := w.Applier.Scale(w.Ctx, w.ServiceName, desired); err != nil {
+		logrus.Errorf("autoscale: failed to scale service %s to %d replicas: %v", w.ServiceName, desired, err)
+		return
+	}
+
+	logrus.Infof("autoscale: service %s scaled %d -> %d replicas (%s)", w.ServiceName, currentReplicas, desired, reason)
+
+	now := time.Now()
+	w.mu.Lock()
+	w.currentReplicas = desired
+	w.cooldownUntil = now.Add(w.Config.Cooldown)
+	w.lastDecision.LastScale = now
+	w.aboveCount, w.belowCount = 0, 0
+	w.mu.Unlock()
+
+	w.persistLastScale()
+}
+
+// Decision returns the watcher's current scaling decision, for ServiceInspect
+// to surface to operators.
+func (w *AutoScaleWatcher) Decision() Decision {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastDecision
+}
+
+// Seed recovers currentReplicas and the cooldown deadline from a previous
+// run's persisted state (see LastScaleFromLabels), so a daemon restart
+// resumes mid-cooldown instead of scaling immediately on the first sample.
+func (w *AutoScaleWatcher) Seed(lastScale time.Time, replicas uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if replicas > 0 {
+		w.currentReplicas = replicas
+	}
+	if !lastScale.IsZero() {
+		w.cooldownUntil = lastScale.Add(w.Config.Cooldown)
+	}
+}
+
+// persistLastScale stores the last-scale timestamp on the service's own
+// annotations, so a daemon restart doesn't forget it mid-cooldown.
+func (w *AutoScaleWatcher) persistLastScale() {
+	w.mu.Lock()
+	currentReplicas, lastScale := w.currentReplicas, w.cooldownUntil.Add(-w.Config.Cooldown)
+	w.mu.Unlock()
+
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		logrus.Errorf("autoscale: %v", err)
+		return
+	}
+	defer cli.Close()
+
+	service, _, err := cli.ServiceInspectWithRaw(w.Ctx, w.ServiceName, types.ServiceInspectOptions{})
+	if err != nil {
+		logrus.Errorf("autoscale: failed to persist last-scale timestamp for service %s: %v", w.ServiceName, err)
+		return
+	}
+
+	if service.Spec.Annotations.Labels == nil {
+		service.Spec.Annotations.Labels = make(map[string]string)
+	}
+	service.Spec.Annotations.Labels[autoScaleLabelPrefix+"lastScale"] = strconv.FormatInt(lastScale.Unix(), 10)
+	service.Spec.Annotations.Labels[autoScaleLabelPrefix+"replicas"] = strconv.FormatUint(currentReplicas, 10)
+
+	if _, err := cli.ServiceUpdate(w.Ctx, service.ID, service.Version, service.Spec, types.ServiceUpdateOptions{}); err != nil {
+		logrus.Errorf("autoscale: failed to persist last-scale timestamp for service %s: %v", w.ServiceName, err)
+	}
+}
+
+// LastScaleFromLabels recovers the last-scale unix timestamp and replica
+// count a previous AutoScaleWatcher persisted, so a daemon restart resumes
+// its cooldown instead of scaling immediately.
+func LastScaleFromLabels(labels map[string]string) (lastScale time.Time, replicas uint64) {
+	if sec, err := strconv.ParseInt(labels[autoScaleLabelPrefix+"lastScale"], 10, 64); err == nil {
+		lastScale = time.Unix(sec, 0)
+	}
+	replicas, _ = strconv.ParseUint(labels[autoScaleLabelPrefix+"replicas"], 10, 64)
+	return
+}
@@ -0,0 +1,169 @@
+package stats // import "github.com/docker/docker/daemon/stats"
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func readCgroupUint64(path string) (uint64, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(raw)), 10, 64)
+}
+
+// clockTicksPerSecond is the kernel's USER_HZ, almost universally 100 on
+// Linux; it's the unit /proc/stat's jiffie counters are reported in.
+const clockTicksPerSecond = 100
+
+// readSystemCPUUsage sums /proc/stat's "cpu" line (the host total across
+// every core) and converts it to nanoseconds, matching the unit
+// CPUStats.SystemUsage is reported in elsewhere (e.g. the swarm-facing
+// collector). AutoRange's CPU-percent formula divides a usage delta by a
+// system delta and multiplies by OnlineCPUs, so SystemUsage must already
+// scale with core count the way this does — wall-clock time does not.
+func readSystemCPUUsage() (uint64, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "cpu" {
+			continue
+		}
+
+		var totalTicks uint64
+		for _, field := range fields[1:] {
+			ticks, err := strconv.ParseUint(field, 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			totalTicks += ticks
+		}
+		return totalTicks * (uint64(time.Second) / clockTicksPerSecond), nil
+	}
+	return 0, scanner.Err()
+}
+
+// readCgroupv2CPUUsec reads the usage_usec line out of a cgroup v2 cpu.stat
+// file, the only field of it AutoRange needs.
+func readCgroupv2CPUUsec(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "usage_usec" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// cgroupUnifiedMarker is present only on a cgroup v2 (unified hierarchy)
+// mount, the same signal the rest of the ecosystem uses to tell v1 and v2
+// apart without parsing /proc/mounts.
+const cgroupUnifiedMarker = "/sys/fs/cgroup/cgroup.controllers"
+
+// NewContainerSampler picks a Cgroupv1Sampler or Cgroupv2Sampler for
+// containerID under the cgroupfs driver's default "docker" parent, so a
+// plain `docker run --autorange` container can drive AutoRange without
+// swarm mode.
+func NewContainerSampler(containerID string) Sampler {
+	if _, err := os.Stat(cgroupUnifiedMarker); err == nil {
+		return &Cgroupv2Sampler{Path: filepath.Join("/sys/fs/cgroup/docker", containerID)}
+	}
+	return &Cgroupv1Sampler{
+		MemoryPath: filepath.Join("/sys/fs/cgroup/memory/docker", containerID),
+		CPUPath:    filepath.Join("/sys/fs/cgroup/cpu,cpuacct/docker", containerID),
+	}
+}
+
+// Cgroupv1Sampler reads memory and CPU usage directly from the cgroup v1
+// hierarchy, bypassing the swarm-facing collector so AutoRange can drive a
+// plain `docker run --autorange` container with no swarm mode required.
+type Cgroupv1Sampler struct {
+	// MemoryPath is the container's memory cgroup, e.g.
+	// /sys/fs/cgroup/memory/docker/<id>.
+	MemoryPath string
+	// CPUPath is the container's cpuacct cgroup, e.g.
+	// /sys/fs/cgroup/cpu,cpuacct/docker/<id>.
+	CPUPath string
+}
+
+// Sample reads memory.usage_in_bytes, memory.max_usage_in_bytes and
+// cpuacct.usage.
+func (c *Cgroupv1Sampler) Sample(ctx context.Context) (Sample, error) {
+	usage, err := readCgroupUint64(filepath.Join(c.MemoryPath, "memory.usage_in_bytes"))
+	if err != nil {
+		return Sample{}, err
+	}
+	maxUsage, err := readCgroupUint64(filepath.Join(c.MemoryPath, "memory.max_usage_in_bytes"))
+	if err != nil {
+		return Sample{}, err
+	}
+	cpuUsage, err := readCgroupUint64(filepath.Join(c.CPUPath, "cpuacct.usage"))
+	if err != nil {
+		return Sample{}, err
+	}
+	systemUsage, err := readSystemCPUUsage()
+	if err != nil {
+		return Sample{}, err
+	}
+
+	var sample Sample
+	sample.Read = time.Now()
+	sample.Stats.MemoryStats.Usage = usage
+	sample.Stats.MemoryStats.MaxUsage = maxUsage
+	sample.Stats.CPUStats.CPUUsage.TotalUsage = cpuUsage
+	sample.Stats.CPUStats.SystemUsage = systemUsage
+	sample.Stats.CPUStats.OnlineCPUs = uint32(runtime.NumCPU())
+	return sample, nil
+}
+
+// Cgroupv2Sampler is the unified-hierarchy equivalent of Cgroupv1Sampler,
+// reading memory.current and the usage_usec field of cpu.stat.
+type Cgroupv2Sampler struct {
+	// Path is the container's unified cgroup, e.g.
+	// /sys/fs/cgroup/system.slice/docker-<id>.scope.
+	Path string
+}
+
+// Sample reads memory.current and cpu.stat's usage_usec.
+func (c *Cgroupv2Sampler) Sample(ctx context.Context) (Sample, error) {
+	usage, err := readCgroupUint64(filepath.Join(c.Path, "memory.current"))
+	if err != nil {
+		return Sample{}, err
+	}
+	cpuUsageUsec, err := readCgroupv2CPUUsec(filepath.Join(c.Path, "cpu.stat"))
+	if err != nil {
+		return Sample{}, err
+	}
+	systemUsage, err := readSystemCPUUsage()
+	if err != nil {
+		return Sample{}, err
+	}
+
+	var sample Sample
+	sample.Read = time.Now()
+	sample.Stats.MemoryStats.Usage = usage
+	sample.Stats.CPUStats.CPUUsage.TotalUsage = cpuUsageUsec * 1000 // usec -> nsec, to match v1's units
+	sample.Stats.CPUStats.SystemUsage = systemUsage
+	sample.Stats.CPUStats.OnlineCPUs = uint32(runtime.NumCPU())
+	return sample, nil
+}
@@ -0,0 +1,76 @@
+package stats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("writeFile(%s): %v", name, err)
+	}
+}
+
+func TestCgroupv1SamplerSample(t *testing.T) {
+	memDir, cpuDir := t.TempDir(), t.TempDir()
+	writeFile(t, memDir, "memory.usage_in_bytes", "104857600\n")
+	writeFile(t, memDir, "memory.max_usage_in_bytes", "209715200\n")
+	writeFile(t, cpuDir, "cpuacct.usage", "123456789\n")
+
+	sampler := &Cgroupv1Sampler{MemoryPath: memDir, CPUPath: cpuDir}
+	sample, err := sampler.Sample(context.Background())
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+
+	if got, want := sample.Stats.MemoryStats.Usage, uint64(104857600); got != want {
+		t.Errorf("MemoryStats.Usage = %d, want %d", got, want)
+	}
+	if got, want := sample.Stats.MemoryStats.MaxUsage, uint64(209715200); got != want {
+		t.Errorf("MemoryStats.MaxUsage = %d, want %d", got, want)
+	}
+	if got, want := sample.Stats.CPUStats.CPUUsage.TotalUsage, uint64(123456789); got != want {
+		t.Errorf("CPUUsage.TotalUsage = %d, want %d", got, want)
+	}
+	if sample.Stats.CPUStats.SystemUsage == 0 {
+		t.Error("SystemUsage = 0, want a real /proc/stat-derived value")
+	}
+	if sample.Stats.CPUStats.OnlineCPUs == 0 {
+		t.Error("OnlineCPUs = 0, want runtime.NumCPU()")
+	}
+}
+
+func TestCgroupv2SamplerSample(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "memory.current", "52428800\n")
+	writeFile(t, dir, "cpu.stat", "usage_usec 9999\nnr_periods 0\nnr_throttled 0\n")
+
+	sampler := &Cgroupv2Sampler{Path: dir}
+	sample, err := sampler.Sample(context.Background())
+	if err != nil {
+		t.Fatalf("Sample() error = %v", err)
+	}
+
+	if got, want := sample.Stats.MemoryStats.Usage, uint64(52428800); got != want {
+		t.Errorf("MemoryStats.Usage = %d, want %d", got, want)
+	}
+	if got, want := sample.Stats.CPUStats.CPUUsage.TotalUsage, uint64(9999*1000); got != want {
+		t.Errorf("CPUUsage.TotalUsage = %d, want %d (usec converted to nsec)", got, want)
+	}
+	if sample.Stats.CPUStats.SystemUsage == 0 {
+		t.Error("SystemUsage = 0, want a real /proc/stat-derived value")
+	}
+}
+
+func TestReadSystemCPUUsageReturnsNonZero(t *testing.T) {
+	usage, err := readSystemCPUUsage()
+	if err != nil {
+		t.Fatalf("readSystemCPUUsage() error = %v", err)
+	}
+	if usage == 0 {
+		t.Fatal("readSystemCPUUsage() = 0, want host total CPU time in nanoseconds")
+	}
+}
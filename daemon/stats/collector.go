@@ -0,0 +1,144 @@
+package stats // import "github.com/docker/docker/daemon/stats"
+
+import (
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/container"
+)
+
+// supervisor is the subset of the daemon that Collector needs to poll a
+// container's live stats, so this package doesn't import daemon (which
+// imports this package).
+type supervisor interface {
+	GetContainerStats(container *container.Container) (*types.StatsJSON, error)
+}
+
+// subscriber is one consumer of a container's stats, fed by Collector's
+// publish loop and drained by ContainerStats/streamOneOfMany.
+type subscriber chan interface{}
+
+// Collector polls every container registered via Collect on interval and
+// publishes each sample to that container's subscribers. AutoRangeWatcher,
+// AutoScaleWatcher and History are independent features layered on top of
+// that same per-container stats flow (see daemon/stats.go), not outputs of
+// the poll loop itself.
+type Collector struct {
+	supervisor supervisor
+	interval   time.Duration
+
+	m           sync.Mutex
+	subscribers map[*container.Container]map[subscriber]struct{}
+
+	// AutoRangeWatcher holds one watcher per container under AutoRange,
+	// keyed by container ID while its Watch runs; a swarm container's watcher
+	// is re-keyed to the swarm service name once Watch returns (see
+	// ContainerStats).
+	AutoRangeWatcher map[string]*AutoRangeWatcher
+
+	// AutoScaleWatcher holds one watcher per swarm service under AutoScale,
+	// keyed by service name.
+	AutoScaleWatcher map[string]*AutoScaleWatcher
+
+	// History is the downsampled stats history store backing
+	// ContainerStatsHistory. It's nil unless the daemon was started with
+	// --stats-retention, in which case NewCollector sizes it from that flag.
+	History *HistoryStore
+}
+
+// NewCollector returns a Collector that polls supervisor every interval.
+// retention enables and sizes the --stats-retention history store; a zero
+// retention leaves History nil, and ContainerStatsHistory then returns no
+// buckets and no error.
+//
+// retention is meant to be sourced from a --stats-retention daemon flag;
+// this checkout doesn't carry daemon/config.go or cmd/dockerd, so that flag
+// isn't defined anywhere yet. Whoever wires NewCollector into daemon
+// construction needs to add it there and pass its value through here.
+func NewCollector(supervisor supervisor, interval, retention time.Duration) *Collector {
+	c := &Collector{
+		supervisor:       supervisor,
+		interval:         interval,
+		subscribers:      make(map[*container.Container]map[subscriber]struct{}),
+		AutoRangeWatcher: make(map[string]*AutoRangeWatcher),
+		AutoScaleWatcher: make(map[string]*AutoScaleWatcher),
+	}
+	if retention > 0 {
+		c.History = NewHistoryStore(retention, time.Second, historyStoreMaxTotalSamples)
+	}
+	return c
+}
+
+// historyStoreMaxTotalSamples bounds the combined size of every container's
+// retained history, so a daemon running many containers under
+// --stats-retention can't grow that store without bound.
+const historyStoreMaxTotalSamples = 1_000_000
+
+// Collect registers c with the collector, starting its poll loop on first
+// use, and returns a channel the caller receives stats frames on until it
+// calls Unsubscribe.
+func (s *Collector) Collect(c *container.Container) chan interface{} {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	subs, exists := s.subscribers[c]
+	if !exists {
+		subs = make(map[subscriber]struct{})
+		s.subscribers[c] = subs
+		go s.publishLoop(c)
+	}
+
+	ch := make(subscriber, 1)
+	subs[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe removes ch from c's subscribers, stopping c's poll loop once
+// its last subscriber is gone.
+func (s *Collector) Unsubscribe(c *container.Container, ch chan interface{}) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	subs, exists := s.subscribers[c]
+	if !exists {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(s.subscribers, c)
+	}
+}
+
+// publishLoop polls c's stats every interval and fans each sample out to its
+// current subscribers, until c has none left.
+func (s *Collector) publishLoop(c *container.Container) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.m.Lock()
+		subs, exists := s.subscribers[c]
+		if !exists {
+			s.m.Unlock()
+			return
+		}
+		recipients := make([]subscriber, 0, len(subs))
+		for ch := range subs {
+			recipients = append(recipients, ch)
+		}
+		s.m.Unlock()
+
+		stat, err := s.supervisor.GetContainerStats(c)
+		if err != nil {
+			continue
+		}
+		for _, ch := range recipients {
+			select {
+			case ch <- *stat:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package stats // import "github.com/docker/docker/daemon/stats"
+
+// MultiStatsFilter selects which containers a multiplexed stats stream
+// subscribes to, so a single request can cover many containers instead of
+// one connection per container.
+type MultiStatsFilter struct {
+	// Containers is an explicit list of container IDs or names to include,
+	// in addition to whatever Labels/Service/All select.
+	Containers []string
+	// Labels, when non-empty, restricts matches to containers carrying all
+	// of these label key/value pairs.
+	Labels map[string]string
+	// Service, when set, restricts matches to containers belonging to this
+	// swarm service name.
+	Service string
+	// All, when true, matches every running container, ignoring Labels and
+	// Service.
+	All bool
+}
+
+// Matches reports whether a container with the given labels (and, for the
+// explicit-list case, ID or name) should be included in the stream.
+func (f MultiStatsFilter) Matches(idOrName string, labels map[string]string) bool {
+	if f.All {
+		return true
+	}
+
+	for _, want := range f.Containers {
+		if want == idOrName {
+			return true
+		}
+	}
+
+	if f.Service == "" && len(f.Labels) == 0 {
+		return false
+	}
+
+	// Service and Labels narrow the match together: a container must satisfy
+	// both when both are set, not either one on its own.
+	if f.Service != "" && labels["com.docker.swarm.service.name"] != f.Service {
+		return false
+	}
+	for k, v := range f.Labels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,248 @@
+package stats // import "github.com/docker/docker/daemon/stats"
+
+import (
+	"sync"
+	"time"
+)
+
+// historySample is one retained point of a container's history, independent
+// of AutoRange's own (much shorter) Observor series.
+type historySample struct {
+	at       time.Time
+	cpuUsage uint64 // cumulative, nanoseconds, as reported by CPUStats.CPUUsage.TotalUsage
+	memUsage uint64
+	memLimit uint64
+}
+
+// HistoryBucket is one downsampled point returned by History.Query, covering
+// every sample recorded in [Since, Until).
+type HistoryBucket struct {
+	Since, Until       time.Time
+	MemUsageMin        uint64
+	MemUsageMax        uint64
+	MemUsageAvg        uint64
+	MemLimit           uint64
+	CPUUsageDeltaTotal uint64 // cumulative CPU ns consumed across the bucket
+}
+
+// History is a first-class, bounded time series of a single container's
+// stats, retained independently of whether AutoRange is enabled for it. It
+// exists so GET .../stats/history can serve a graph without the caller
+// having to stream every tick.
+type History struct {
+	retention time.Duration
+	period    time.Duration
+
+	mu      sync.Mutex
+	samples []historySample
+}
+
+// NewHistory returns a History that keeps samples no older than retention,
+// recording at most one sample per period.
+func NewHistory(retention, period time.Duration) *History {
+	return &History{retention: retention, period: period}
+}
+
+// Record adds frame's memory/CPU usage at time now, provided at least period
+// has elapsed since the last recorded sample, and evicts anything older than
+// retention.
+func (h *History) Record(now time.Time, cpuUsage, memUsage, memLimit uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if n := len(h.samples); n > 0 && now.Sub(h.samples[n-1].at) < h.period {
+		return
+	}
+
+	h.samples = append(h.samples, historySample{at: now, cpuUsage: cpuUsage, memUsage: memUsage, memLimit: memLimit})
+	h.evictOlderThanLocked(now)
+}
+
+func (h *History) evictOlderThanLocked(now time.Time) {
+	cutoff := now.Add(-h.retention)
+	i := 0
+	for i < len(h.samples) && h.samples[i].at.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		h.samples = h.samples[i:]
+	}
+}
+
+// count reports how many samples are currently retained, for HistoryStore's
+// memory cap enforcement.
+func (h *History) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.samples)
+}
+
+// dropOldest removes the single oldest retained sample, if any.
+func (h *History) dropOldest() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.samples) > 0 {
+		h.samples = h.samples[1:]
+	}
+}
+
+// maxHistoryQueryBuckets bounds how many buckets a single Query call will
+// produce, regardless of the requested step, so a caller-supplied step far
+// smaller than (until-since) can't make the daemon iterate an unbounded
+// number of times.
+const maxHistoryQueryBuckets = 10_000
+
+// Query downsamples the retained samples in [since, until) into buckets of
+// width step, aggregating each with min/max/avg server-side. step is
+// clamped to at least the store's own sampling period, and widened further
+// if needed to keep the number of buckets within maxHistoryQueryBuckets.
+func (h *History) Query(since, until time.Time, step time.Duration) []HistoryBucket {
+	h.mu.Lock()
+	samples := make([]historySample, len(h.samples))
+	copy(samples, h.samples)
+	h.mu.Unlock()
+
+	if step < h.period {
+		step = h.period
+	}
+	if span := until.Sub(since); span > 0 && span/step > maxHistoryQueryBuckets {
+		step = span / maxHistoryQueryBuckets
+	}
+
+	var buckets []HistoryBucket
+	for bucketStart := since; bucketStart.Before(until); bucketStart = bucketStart.Add(step) {
+		bucketEnd := bucketStart.Add(step)
+
+		var (
+			min, max, sum, count uint64
+			limit                uint64
+			firstCPU, lastCPU    uint64
+			haveCPU              bool
+		)
+		for _, s := range samples {
+			if s.at.Before(bucketStart) || !s.at.Before(bucketEnd) {
+				continue
+			}
+			if count == 0 || s.memUsage < min {
+				min = s.memUsage
+			}
+			if s.memUsage > max {
+				max = s.memUsage
+			}
+			sum += s.memUsage
+			limit = s.memLimit
+			if !haveCPU {
+				firstCPU = s.cpuUsage
+				haveCPU = true
+			}
+			lastCPU = s.cpuUsage
+			count++
+		}
+		if count == 0 {
+			continue
+		}
+
+		buckets = append(buckets, HistoryBucket{
+			Since:              bucketStart,
+			Until:              bucketEnd,
+			MemUsageMin:        min,
+			MemUsageMax:        max,
+			MemUsageAvg:        sum / count,
+			MemLimit:           limit,
+			CPUUsageDeltaTotal: lastCPU - firstCPU,
+		})
+	}
+	return buckets
+}
+
+// HistoryStore owns one History per container, applying a shared retention
+// window and sampling period plus a hard cap on the total number of samples
+// retained across every series, so a daemon watching many containers can't
+// grow its history without bound.
+type HistoryStore struct {
+	retention       time.Duration
+	period          time.Duration
+	maxTotalSamples int
+
+	mu     sync.Mutex
+	series map[string]*History
+}
+
+// NewHistoryStore returns a store backing the --stats-retention daemon flag:
+// retention/period size each container's History, maxTotalSamples bounds the
+// sum of samples kept across all of them.
+func NewHistoryStore(retention, period time.Duration, maxTotalSamples int) *HistoryStore {
+	return &HistoryStore{
+		retention:       retention,
+		period:          period,
+		maxTotalSamples: maxTotalSamples,
+		series:          make(map[string]*History),
+	}
+}
+
+func (s *HistoryStore) getOrCreate(containerID string) *History {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.series[containerID]
+	if !ok {
+		h = NewHistory(s.retention, s.period)
+		s.series[containerID] = h
+	}
+	return h
+}
+
+// Record stores one sample for containerID and enforces the store-wide
+// memory cap.
+func (s *HistoryStore) Record(containerID string, now time.Time, cpuUsage, memUsage, memLimit uint64) {
+	s.getOrCreate(containerID).Record(now, cpuUsage, memUsage, memLimit)
+	s.enforceCap()
+}
+
+// enforceCap drops the oldest sample from whichever series currently holds
+// the most, one at a time, until the total is back under the cap. This is a
+// coarse global LRU-by-series rather than a perfectly time-ordered eviction,
+// which is enough to bound memory without a cross-series merge on every
+// write.
+func (s *HistoryStore) enforceCap() {
+	if s.maxTotalSamples <= 0 {
+		return
+	}
+	for {
+		s.mu.Lock()
+		total := 0
+		var fullest *History
+		fullestCount := 0
+		for _, h := range s.series {
+			c := h.count()
+			total += c
+			if c > fullestCount {
+				fullest, fullestCount = h, c
+			}
+		}
+		s.mu.Unlock()
+
+		if total <= s.maxTotalSamples || fullest == nil {
+			return
+		}
+		fullest.dropOldest()
+	}
+}
+
+// Query returns the downsampled history for containerID, or nil if nothing
+// has been recorded for it yet.
+func (s *HistoryStore) Query(containerID string, since, until time.Time, step time.Duration) []HistoryBucket {
+	s.mu.Lock()
+	h, ok := s.series[containerID]
+	s.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return h.Query(since, until, step)
+}
+
+// Evict drops containerID's history entirely, e.g. on container removal.
+func (s *HistoryStore) Evict(containerID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.series, containerID)
+}
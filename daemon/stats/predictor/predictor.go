@@ -0,0 +1,163 @@
+// Package predictor provides O(1)-memory streaming estimators used by
+// AutoRange to turn a stream of samples into a min/max prediction without
+// keeping every sample around. It replaces flat moving averages, which
+// weight a single-window spike the same as the entire retention, with an
+// exponentially weighted moving average for the center of the distribution
+// and a P² quantile estimator for its tail.
+package predictor // import "github.com/docker/docker/daemon/stats/predictor"
+
+// EWMA is an exponentially weighted moving average. Observe(x) costs O(1)
+// time and the estimator uses O(1) memory regardless of how many samples
+// have been observed.
+type EWMA struct {
+	alpha float64
+	value float64
+	init  bool
+}
+
+// NewEWMA returns an EWMA with the given smoothing factor. alpha closer to 1
+// tracks recent samples more closely; alpha closer to 0 smooths harder.
+func NewEWMA(alpha float64) *EWMA {
+	return &EWMA{alpha: alpha}
+}
+
+// Observe folds x into the running average.
+func (e *EWMA) Observe(x float64) {
+	if !e.init {
+		e.value = x
+		e.init = true
+		return
+	}
+	e.value = e.alpha*x + (1-e.alpha)*e.value
+}
+
+// Value returns the current estimate, or 0 if nothing has been observed yet.
+func (e *EWMA) Value() float64 {
+	return e.value
+}
+
+// P2Quantile is Jain & Chlamtac's P² algorithm: it estimates a single
+// quantile from a data stream using five markers and O(1) memory, with no
+// need to retain or sort the observed samples.
+type P2Quantile struct {
+	q float64
+
+	// heights holds the marker heights, positions their positions,
+	// desiredPositions the desired (fractional) positions, and increments the
+	// per-observation increment of desiredPositions.
+	heights          [5]float64
+	positions        [5]float64
+	desiredPositions [5]float64
+	increments       [5]float64
+
+	count int
+}
+
+// NewP2Quantile returns an estimator for the given quantile, 0 < q < 1.
+func NewP2Quantile(q float64) *P2Quantile {
+	return &P2Quantile{
+		q:          q,
+		increments: [5]float64{0, q / 2, q, (1 + q) / 2, 1},
+	}
+}
+
+// Observe folds x into the estimator.
+func (p *P2Quantile) Observe(x float64) {
+	p.count++
+
+	if p.count <= 5 {
+		p.initialize(x)
+		return
+	}
+
+	k := p.cell(x)
+
+	for i := k + 1; i < 5; i++ {
+		p.positions[i]++
+	}
+	for i := 0; i < 5; i++ {
+		p.desiredPositions[i] += p.increments[i]
+	}
+
+	for i := 1; i < 4; i++ {
+		d := p.desiredPositions[i] - p.positions[i]
+		if (d >= 1 && p.positions[i+1]-p.positions[i] > 1) || (d <= -1 && p.positions[i-1]-p.positions[i] < -1) {
+			sign := 1.0
+			if d < 0 {
+				sign = -1.0
+			}
+			parabolic := p.parabolic(i, sign)
+			if p.heights[i-1] < parabolic && parabolic < p.heights[i+1] {
+				p.heights[i] = parabolic
+			} else {
+				p.heights[i] = p.linear(i, sign)
+			}
+			p.positions[i] += sign
+		}
+	}
+}
+
+// initialize handles the first five observations, which just seed the
+// markers in sorted order before the P² recurrence can be applied.
+func (p *P2Quantile) initialize(x float64) {
+	i := p.count - 1
+	p.heights[i] = x
+	if p.count == 5 {
+		// Sort the five seed heights and set the initial marker positions.
+		for i := 1; i < 5; i++ {
+			for j := i; j > 0 && p.heights[j] < p.heights[j-1]; j-- {
+				p.heights[j], p.heights[j-1] = p.heights[j-1], p.heights[j]
+			}
+		}
+		for i := range p.positions {
+			p.positions[i] = float64(i + 1)
+		}
+		p.desiredPositions = [5]float64{1, 1 + 2*p.q, 1 + 4*p.q, 3 + 2*p.q, 5}
+	}
+}
+
+// cell finds k such that heights[k] <= x < heights[k+1], clamping x into the
+// known range and adjusting the extreme markers as P² requires.
+func (p *P2Quantile) cell(x float64) int {
+	switch {
+	case x < p.heights[0]:
+		p.heights[0] = x
+		return 0
+	case x >= p.heights[4]:
+		p.heights[4] = x
+		return 3
+	}
+	for k := 0; k < 4; k++ {
+		if p.heights[k] <= x && x < p.heights[k+1] {
+			return k
+		}
+	}
+	return 3
+}
+
+func (p *P2Quantile) parabolic(i int, sign float64) float64 {
+	return p.heights[i] + sign/(p.positions[i+1]-p.positions[i-1])*
+		((p.positions[i]-p.positions[i-1]+sign)*(p.heights[i+1]-p.heights[i])/(p.positions[i+1]-p.positions[i])+
+			(p.positions[i+1]-p.positions[i]-sign)*(p.heights[i]-p.heights[i-1])/(p.positions[i]-p.positions[i-1]))
+}
+
+func (p *P2Quantile) linear(i int, sign float64) float64 {
+	next := i + int(sign)
+	return p.heights[i] + sign*(p.heights[next]-p.heights[i])/(p.positions[next]-p.positions[i])
+}
+
+// Value returns the current quantile estimate. Before 5 samples have been
+// observed it returns the highest seen value, which is the best estimate
+// available with that little data.
+func (p *P2Quantile) Value() float64 {
+	if p.count < 5 {
+		best := 0.0
+		for i := 0; i < p.count; i++ {
+			if p.heights[i] > best {
+				best = p.heights[i]
+			}
+		}
+		return best
+	}
+	return p.heights[2]
+}
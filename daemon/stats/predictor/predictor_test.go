@@ -0,0 +1,61 @@
+package predictor
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestEWMASmoothsTowardsRecentValues(t *testing.T) {
+	e := NewEWMA(0.5)
+
+	if got := e.Value(); got != 0 {
+		t.Fatalf("Value before any Observe = %v, want 0", got)
+	}
+
+	e.Observe(10)
+	if got := e.Value(); got != 10 {
+		t.Fatalf("Value after first Observe = %v, want 10 (first sample seeds the average)", got)
+	}
+
+	e.Observe(20)
+	if got, want := e.Value(), 15.0; got != want {
+		t.Fatalf("Value after second Observe = %v, want %v", got, want)
+	}
+}
+
+func TestP2QuantileMatchesSortedPercentileOnUniformData(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	samples := make([]float64, 2000)
+	for i := range samples {
+		samples[i] = r.Float64() * 1000
+	}
+
+	for _, q := range []float64{0.5, 0.95, 0.99} {
+		p := NewP2Quantile(q)
+		for _, s := range samples {
+			p.Observe(s)
+		}
+
+		sorted := append([]float64(nil), samples...)
+		sort.Float64s(sorted)
+		want := sorted[int(q*float64(len(sorted)))]
+
+		got := p.Value()
+		if tolerance := 0.05 * want; math.Abs(got-want) > tolerance+1 {
+			t.Errorf("q=%v: P2Quantile.Value() = %v, want ~%v (tolerance %v)", q, got, want, tolerance)
+		}
+	}
+}
+
+func TestP2QuantileBeforeFiveSamplesReturnsHighestSeen(t *testing.T) {
+	p := NewP2Quantile(0.5)
+	p.Observe(3)
+	p.Observe(7)
+	p.Observe(5)
+
+	if got, want := p.Value(), 7.0; got != want {
+		t.Fatalf("Value() with 3 samples = %v, want %v (highest seen)", got, want)
+	}
+}
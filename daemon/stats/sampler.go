@@ -0,0 +1,67 @@
+package stats // import "github.com/docker/docker/daemon/stats"
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	ctn "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// Sample is the minimal set of metrics AutoRangeWatcher needs on each tick,
+// whatever its Sampler ultimately reads from.
+type Sample = types.StatsJSON
+
+// Sampler produces one Sample per call, blocking until one is available or
+// ctx is done. AutoRangeWatcher calls it once per tick instead of reading
+// straight off a swarm-collector channel, so it can run against any metrics
+// source.
+type Sampler interface {
+	Sample(ctx context.Context) (Sample, error)
+}
+
+// Applier applies a resource update to a single container, whatever the
+// underlying mechanism (the local daemon API, a remote one, ...).
+type Applier interface {
+	Apply(ctx context.Context, containerID string, update ctn.UpdateConfig) error
+}
+
+// SwarmStatsSampler is the original Sampler: it surfaces whatever frame the
+// swarm-facing collector in daemon/stats.go last pushed in, blocking until
+// one arrives or ctx is cancelled.
+type SwarmStatsSampler struct {
+	in <-chan Sample
+}
+
+// NewSwarmStatsSampler wraps the channel ContainerStats feeds on every tick.
+func NewSwarmStatsSampler(in <-chan Sample) *SwarmStatsSampler {
+	return &SwarmStatsSampler{in: in}
+}
+
+// Sample returns the next frame pushed on the wrapped channel.
+func (s *SwarmStatsSampler) Sample(ctx context.Context) (Sample, error) {
+	select {
+	case frame := <-s.in:
+		return frame, nil
+	case <-ctx.Done():
+		return Sample{}, ctx.Err()
+	}
+}
+
+// LocalDaemonApplier applies limits through the local Docker API, the same
+// way AutoRangeWatcher always has.
+type LocalDaemonApplier struct{}
+
+// Apply updates containerID's resources via a fresh client built from the
+// environment, matching the daemon's existing conventions elsewhere in this
+// package.
+func (LocalDaemonApplier) Apply(ctx context.Context, containerID string, update ctn.UpdateConfig) error {
+	cli, err := client.NewEnvClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	_, err = cli.ContainerUpdate(ctx, containerID, update)
+	return err
+}